@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//PKTeam 和 serve 預設看最近幾場
+var atsLookback = 5
+
+//某隊一場比賽的讓分結果
+type ATSGame struct {
+	Time     string  `json:"time"` //北京時間
+	Opponent string  `json:"opponent"`
+	Home     bool    `json:"home"`
+	Line     float64 `json:"line"`   //這隊的讓分，-5.5 表示讓 5.5 分
+	Margin   int     `json:"margin"` //這隊贏幾分，輸球是負數
+	Result   string  `json:"result"` //cover、push、fail
+}
+
+//titan007 近期過盤的一場，l1.js 只有結果，讀得到 matchResult 時是那一場的盤口和淨勝分
+type DishResult struct {
+	Time     string  `json:"time,omitempty"` //北京時間，只有 l1.js 時是空白
+	Opponent string  `json:"opponent,omitempty"`
+	Result   string  `json:"result"` //cover、push、fail、unknown
+	Line     float64 `json:"line"`   //這隊的讓分
+	Margin   int     `json:"margin"` //這隊贏幾分
+	HasLine  bool    `json:"hasLine"`
+}
+
+//eg: "贏(-5.5 +8)"，沒有盤口只顯示結果
+func (d DishResult) Text() string {
+	if !d.HasLine {
+		return atsLabel(d.Result)
+	}
+	return fmt.Sprintf("%s(%+.1f %+d)", atsLabel(d.Result), d.Line, d.Margin)
+}
+
+//eg: "贏(-5.5 +8),走(+3.0 -3),輸"
+func dishText(results []DishResult) string {
+	var labels []string
+	for _, v := range results {
+		labels = append(labels, v.Text())
+	}
+	return strings.Join(labels, ",")
+}
+
+//每場讓分資料各自轉成一筆近期過盤，用日期和對手對應，結果是用盤口和淨勝分算的
+func dishFromATS(games []ATSGame) (result []DishResult) {
+	for _, g := range games {
+		result = append(result, DishResult{
+			Time:     g.Time,
+			Opponent: g.Opponent,
+			Result:   g.Result,
+			Line:     g.Line,
+			Margin:   g.Margin,
+			HasLine:  true,
+		})
+	}
+	return result
+}
+
+//過盤、走盤、輸盤的場數
+type ATSSplit struct {
+	Cover int `json:"cover"`
+	Fail  int `json:"fail"`
+	Push  int `json:"push"`
+}
+
+//某隊最近 n 場的讓分統計
+type ATSStats struct {
+	Games       []ATSGame `json:"games"` //最新的在前面
+	Total       ATSSplit  `json:"total"`
+	CoverPct    float64   `json:"coverPct"` //不算走盤
+	Streak      string    `json:"streak"`   //目前連續的結果
+	StreakCount int       `json:"streakCount"`
+	Home        ATSSplit  `json:"home"`
+	Away        ATSSplit  `json:"away"`
+	Favorite    ATSSplit  `json:"favorite"` //讓分方
+	Underdog    ATSSplit  `json:"underdog"` //受讓方
+}
+
+func (s *ATSSplit) add(result string) {
+	switch result {
+	case ATSCover:
+		s.Cover++
+	case ATSFail:
+		s.Fail++
+	case ATSPush:
+		s.Push++
+	}
+}
+
+//eg: "3-1-1"，過-輸-走
+func (s ATSSplit) String() string {
+	return fmt.Sprintf("%d-%d-%d", s.Cover, s.Fail, s.Push)
+}
+
+//用 titan007 的讓分和最終比分算出某隊最近 n 場的讓分結果
+func teamATS(matches []TitanMatch, team string, n int) ATSStats {
+	recent := recentTitanMatches(matches, team, n, func(m TitanMatch) bool {
+		return m.HasLine
+	})
+
+	var games []ATSGame
+	for _, m := range recent {
+		game := ATSGame{Time: m.Time.Format("2006-01-02 15:04")}
+		homeResult, awayResult := settleSpread(-m.Line, m.HomeScore, m.AwayScore)
+
+		if sameTeam(m.Home, team) {
+			game.Home = true
+			game.Opponent = m.Away
+			game.Line = -m.Line
+			game.Margin = m.HomeScore - m.AwayScore
+			game.Result = homeResult
+		} else {
+			game.Opponent = m.Home
+			game.Line = m.Line
+			game.Margin = m.AwayScore - m.HomeScore
+			game.Result = awayResult
+		}
+
+		games = append(games, game)
+	}
+	return atsStats(games)
+}
+
+//用資料庫內已結算的讓分算出某隊最近 n 場同一種比賽的結果
+//
+//titan007 沒有季前賽和明星賽的盤口，這兩種比賽只看 spread set 手動記錄的讓分，時間是美東日期
+func storedATS(spreads map[string]Spread, team, seasonType string, n int) ATSStats {
+	var list []Spread
+	for _, v := range spreads {
+		if !v.Settled || len(v.GameID) < 3 || v.GameID[2:3] != seasonType {
+			continue
+		}
+		if sameTeam(v.HomeTeam, team) || sameTeam(v.AwayTeam, team) {
+			list = append(list, v)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Date > list[j].Date
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+
+	var games []ATSGame
+	for _, v := range list {
+		game := ATSGame{Time: v.Date}
+		if sameTeam(v.HomeTeam, team) {
+			game.Home = true
+			game.Opponent = v.AwayTeam
+			game.Line = v.Line
+			game.Margin = v.HomeScore - v.AwayScore
+			game.Result = v.HomeResult
+		} else {
+			game.Opponent = v.HomeTeam
+			game.Line = -v.Line
+			game.Margin = v.AwayScore - v.HomeScore
+			game.Result = v.AwayResult
+		}
+		games = append(games, game)
+	}
+	return atsStats(games)
+}
+
+//把讓分結果整理成統計，games 最新的在前面
+func atsStats(games []ATSGame) (result ATSStats) {
+	for _, game := range games {
+		result.Games = append(result.Games, game)
+		result.Total.add(game.Result)
+		if game.Home {
+			result.Home.add(game.Result)
+		} else {
+			result.Away.add(game.Result)
+		}
+		switch {
+		case game.Line < 0:
+			result.Favorite.add(game.Result)
+		case game.Line > 0:
+			result.Underdog.add(game.Result)
+		}
+	}
+
+	if decided := result.Total.Cover + result.Total.Fail; decided > 0 {
+		result.CoverPct = float64(result.Total.Cover) / float64(decided) * 100
+	}
+
+	for i, g := range result.Games {
+		if i > 0 && g.Result != result.Games[0].Result {
+			break
+		}
+		result.Streak = g.Result
+		result.StreakCount++
+	}
+
+	return result
+}
+
+//eg: "贏,輸,走,贏,贏 過盤率 75.0% 連贏2 主 2-0-1 客 1-1-0 讓分 2-1-0 受讓 1-0-1"
+func (s ATSStats) Text() string {
+	if len(s.Games) == 0 {
+		return "無資料"
+	}
+
+	var labels []string
+	for _, g := range s.Games {
+		labels = append(labels, atsLabel(g.Result))
+	}
+
+	return fmt.Sprintf("%s 過盤率 %.1f%% 連%s%d 主 %s 客 %s 讓分 %s 受讓 %s",
+		strings.Join(labels, ","), s.CoverPct, atsLabel(s.Streak), s.StreakCount,
+		s.Home, s.Away, s.Favorite, s.Underdog)
+}
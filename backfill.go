@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+//scanNBA backfill -from 2022-10-18 [-to 2023-04-09] [-delay 500ms] [-season 2022-23]
+//
+//把過去每一天的 daily.json 存進資料庫，-to 預設是昨天，有 -season 時預設是整個球季
+func backfillCmd(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	from := fs.String("from", "", "開始日期 yyyy-mm-dd")
+	to := fs.String("to", gameDate(time.Now().AddDate(0, 0, -1)), "結束日期 yyyy-mm-dd")
+	delay := fs.Duration("delay", 500*time.Millisecond, "每次 request 之間等待的時間")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	//有 -season 時沒給的日期用整個球季，最晚到昨天
+	if seasonOverride != "" {
+		first, last, err := seasonDates(seasonOverride)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if *from == "" {
+			*from = first
+		}
+		if yesterday := gameDate(time.Now().AddDate(0, 0, -1)); last < yesterday && !flagSet(fs, "to") {
+			*to = last
+		}
+	}
+
+	start, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		fmt.Println("開始日期格式錯誤: ", err)
+		return
+	}
+	end, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		fmt.Println("結束日期格式錯誤: ", err)
+		return
+	}
+
+	err = withStore(func(s *Store) error {
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			date := d.Format("2006-01-02")
+
+			schedule, err := getSchedule(date)
+			if err != nil {
+				return fmt.Errorf("%s: %v", date, err)
+			}
+			if err := s.SaveSchedule(date, schedule); err != nil {
+				return fmt.Errorf("%s: %v", date, err)
+			}
+			fmt.Println(date, len(schedule.Payload.Date.Games), "場比賽")
+
+			time.Sleep(*delay)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+//回測時每場比賽的選擇，side 是 home、away 或空白(不下注)，prob 是選的那一方過盤的機率，沒有就是 0
+type backtestPick struct {
+	side string
+	prob float64
+}
+
+//回測用的策略，只能看到比賽日之前的資料
+type backtestStrategy func(in PredictInput) backtestPick
+
+//跟著近期過盤好的隊伍下注，兩隊都符合就不下
+func trendStrategy(k int, fade bool) backtestStrategy {
+	return func(in PredictInput) backtestPick {
+		home := in.Home.ATS.Total.Cover >= k
+		away := in.Away.ATS.Total.Cover >= k
+		if home == away {
+			return backtestPick{}
+		}
+
+		side := "home"
+		if away {
+			side = "away"
+		}
+		if fade {
+			side = otherSide(side)
+		}
+		return backtestPick{side: side}
+	}
+}
+
+//用預測模型的過盤機率下注，機率和五成差 edge 以上才下
+func predictorStrategy(p Predictor, edge float64) backtestStrategy {
+	return func(in PredictInput) backtestPick {
+		prob := p.Predict(in).CoverProb
+		switch {
+		case prob >= 0.5+edge:
+			return backtestPick{side: "home", prob: prob}
+		case prob <= 0.5-edge:
+			return backtestPick{side: "away", prob: 1 - prob}
+		}
+		return backtestPick{}
+	}
+}
+
+func otherSide(side string) string {
+	if side == "home" {
+		return "away"
+	}
+	return "home"
+}
+
+//一個機率區間內的預測和實際結果
+type calibrationBucket struct {
+	low, high float64
+	games     int
+	wins      int
+}
+
+//回測結果
+type BacktestResult struct {
+	From, To    string
+	Bets        int
+	Wins        int
+	Losses      int
+	Pushes      int
+	Risked      float64 //總共投入幾單位
+	Profit      float64
+	MaxDrawdown float64
+
+	calibration []calibrationBucket
+}
+
+func (r BacktestResult) WinPct() float64 {
+	if r.Wins+r.Losses == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Wins+r.Losses) * 100
+}
+
+func (r BacktestResult) ROI() float64 {
+	if r.Risked == 0 {
+		return 0
+	}
+	return r.Profit / r.Risked * 100
+}
+
+func (r BacktestResult) Text() string {
+	msg := fmt.Sprintf("%s ~ %s\n", r.From, r.To)
+	msg = msg + fmt.Sprintf("下注 %d 場 贏 %d 輸 %d 走 %d 勝率 %.1f%%\n", r.Bets, r.Wins, r.Losses, r.Pushes, r.WinPct())
+	msg = msg + fmt.Sprintf("投入 %.1f 獲利 %+.1f ROI %.1f%%\n", r.Risked, r.Profit, r.ROI())
+	msg = msg + fmt.Sprintf("最大回撤 %.1f", r.MaxDrawdown)
+
+	var lines string
+	for _, b := range r.calibration {
+		if b.games == 0 {
+			continue
+		}
+		lines = lines + fmt.Sprintf("\n  %2.0f-%2.0f%%  %3d 場 實際 %.1f%%", b.low*100, b.high*100, b.games, float64(b.wins)/float64(b.games)*100)
+	}
+	if lines != "" {
+		msg = msg + "\n校準:" + lines
+	}
+	return msg
+}
+
+//回測過程中某隊到目前為止的資料
+type backtestTeam struct {
+	wins, losses int
+	ats          []ATSGame //最新的在前面
+}
+
+//用資料庫內的讓分和比分一天一天重跑，每天只用前一天以前的戰績、讓分和賽程
+func runBacktest(spreads []Spread, scores map[string]FinalScore, games map[string][]Game, strategy backtestStrategy, lookback int, vig float64) (result BacktestResult) {
+	for low := 0.5; low < 1; low = low + 0.05 {
+		result.calibration = append(result.calibration, calibrationBucket{low: low, high: low + 0.05})
+	}
+
+	byDate := make(map[string][]Spread)
+	var dates []string
+	for _, v := range spreads {
+		if _, ok := scores[v.GameID]; !ok {
+			continue
+		}
+		if len(byDate[v.Date]) == 0 {
+			dates = append(dates, v.Date)
+		}
+		byDate[v.Date] = append(byDate[v.Date], v)
+	}
+	sort.Strings(dates)
+	if len(dates) == 0 {
+		return result
+	}
+	result.From, result.To = dates[0], dates[len(dates)-1]
+
+	teams := make(map[string]*backtestTeam)
+	team := func(name string) *backtestTeam {
+		if name == "LA Clippers" {
+			name = "Los Angeles Clippers"
+		}
+		if teams[name] == nil {
+			teams[name] = &backtestTeam{}
+		}
+		return teams[name]
+	}
+
+	//戰績用所有比完的例行賽，不只有讓分的；季前賽、明星賽、季後賽和夏季聯賽不算，和 latestRecords 一樣
+	regular := make(map[string]bool)
+	for _, list := range games {
+		for _, g := range list {
+			if g.SeasonType() == SeasonRegular && !g.SummerLeague() {
+				regular[g.Profile.GameID] = true
+			}
+		}
+	}
+	var finals []FinalScore
+	for _, v := range scores {
+		if regular[v.GameID] {
+			finals = append(finals, v)
+		}
+	}
+	sort.SliceStable(finals, func(i, j int) bool {
+		return finals[i].Date < finals[j].Date
+	})
+
+	//每季重新累積戰績和讓分，上一季的比賽不算
+	backtestSeason := func(date string) string {
+		t, _ := time.Parse("2006-01-02", date)
+		return dishSeason(t)
+	}
+
+	var (
+		peak    float64
+		current string
+	)
+	next := 0
+	for _, date := range dates {
+		if season := backtestSeason(date); season != current {
+			current = season
+			teams = make(map[string]*backtestTeam)
+		}
+		for ; next < len(finals) && finals[next].Date < date; next++ {
+			if backtestSeason(finals[next].Date) != current {
+				continue
+			}
+			home, away := team(finals[next].HomeTeam), team(finals[next].AwayTeam)
+			if finals[next].HomeScore > finals[next].AwayScore {
+				home.wins++
+				away.losses++
+			} else {
+				away.wins++
+				home.losses++
+			}
+		}
+		slates := backtestSlates(games, date)
+
+		for _, v := range byDate[date] {
+			score := scores[v.GameID]
+			in := PredictInput{GameID: v.GameID, Date: date, Line: v.Line, HasLine: true}
+			for _, side := range []struct {
+				report *TeamReport
+				name   string
+			}{{&in.Home, v.HomeTeam}, {&in.Away, v.AwayTeam}} {
+				t := team(side.name)
+				ats := t.ats
+				if len(ats) > lookback {
+					ats = ats[:lookback]
+				}
+				*side.report = TeamReport{
+					Name:   side.name,
+					ATS:    atsStats(ats),
+					Record: TeamRecord{Team: side.name, Wins: t.wins, Losses: t.losses, Date: date},
+					Rest:   teamRest(slates, side.name, backtestArena(games[date], v.GameID)),
+				}
+			}
+
+			pick := strategy(in)
+			if pick.side == "" {
+				continue
+			}
+
+			homeResult, awayResult := settleSpread(v.Line, score.HomeScore, score.AwayScore)
+			outcome := homeResult
+			if pick.side == "away" {
+				outcome = awayResult
+			}
+
+			result.Bets++
+			result.Risked = result.Risked + vig/100
+			switch outcome {
+			case ATSCover:
+				result.Wins++
+				result.Profit = result.Profit + 1
+			case ATSFail:
+				result.Losses++
+				result.Profit = result.Profit - vig/100
+			case ATSPush:
+				result.Pushes++
+				result.Risked = result.Risked - vig/100
+			}
+
+			if result.Profit > peak {
+				peak = result.Profit
+			}
+			if peak-result.Profit > result.MaxDrawdown {
+				result.MaxDrawdown = peak - result.Profit
+			}
+
+			if pick.prob > 0 && outcome != ATSPush {
+				for i := range result.calibration {
+					b := &result.calibration[i]
+					if pick.prob >= b.low && (pick.prob < b.high || i == len(result.calibration)-1) {
+						b.games++
+						if outcome == ATSCover {
+							b.wins++
+						}
+						break
+					}
+				}
+			}
+		}
+
+		//當天所有比賽都下完注之後才更新讓分，避免看到當天的結果
+		for _, v := range byDate[date] {
+			score := scores[v.GameID]
+			homeResult, awayResult := settleSpread(v.Line, score.HomeScore, score.AwayScore)
+			home, away := team(v.HomeTeam), team(v.AwayTeam)
+
+			home.ats = append([]ATSGame{{Time: date, Opponent: v.AwayTeam, Home: true, Line: v.Line, Margin: score.HomeScore - score.AwayScore, Result: homeResult}}, home.ats...)
+			away.ats = append([]ATSGame{{Time: date, Opponent: v.HomeTeam, Line: -v.Line, Margin: score.AwayScore - score.HomeScore, Result: awayResult}}, away.ats...)
+		}
+	}
+	return result
+}
+
+//比賽日前 7 天存在資料庫內的賽程，格式和 recentSlates 一樣
+func backtestSlates(games map[string][]Game, date string) (result [][]Game) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil
+	}
+	for i := 1; i <= 7; i++ {
+		result = append(result, games[day.AddDate(0, 0, -i).Format("2006-01-02")])
+	}
+	return result
+}
+
+func backtestArena(games []Game, gameID string) string {
+	for _, g := range games {
+		if g.Profile.GameID == gameID {
+			return g.Profile.ArenaLocation
+		}
+	}
+	return ""
+}
+
+//scanNBA backtest [-strategy trend|fade|predict] [-n 5] [-k 4] [-edge 0.05] [-vig 110] [-from 2022-10-18] [-to 2023-04-09] [-season 2022-23]
+//
+//用資料庫內的讓分(spread record/set)和比分(backfill)回測策略
+func backtestCmd(args []string) {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	name := fs.String("strategy", "trend", "trend: 跟近期過盤好的隊伍，fade: 反著下，predict: 用預測模型")
+	lookback := fs.Int("n", atsLookback, "看最近幾場讓分")
+	k := fs.Int("k", 4, "最近 n 場過盤幾場以上才算近況好")
+	edge := fs.Float64("edge", 0.05, "predict 的過盤機率要比五成多多少才下注")
+	vig := fs.Float64("vig", 110, "下注 vig 贏 100，美式賠率 -110 就是 110")
+	from := fs.String("from", "", "開始日期 yyyy-mm-dd，空白表示從頭開始")
+	to := fs.String("to", "", "結束日期 yyyy-mm-dd，空白表示到最後")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	first, last, err := seasonRange()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *from == "" {
+		*from = first
+	}
+	if *to == "" {
+		*to = last
+	}
+
+	var strategy backtestStrategy
+	switch *name {
+	case "trend":
+		strategy = trendStrategy(*k, false)
+	case "fade":
+		strategy = trendStrategy(*k, true)
+	case "predict":
+		strategy = predictorStrategy(defaultPredictor, *edge)
+	default:
+		fmt.Println("未知的策略: " + *name)
+		return
+	}
+
+	var spreads []Spread
+	scores := make(map[string]FinalScore)
+	games := make(map[string][]Game)
+	err = withStore(func(s *Store) error {
+		list, err := s.Spreads()
+		if err != nil {
+			return err
+		}
+		for _, v := range list {
+			if inRange(v.Date, *from, *to) {
+				spreads = append(spreads, v)
+			}
+		}
+
+		finals, err := s.Scores()
+		if err != nil {
+			return err
+		}
+		for _, v := range finals {
+			scores[v.GameID] = v
+		}
+
+		return s.EachGame(func(date string, g Game) error {
+			games[date] = append(games[date], g)
+			return nil
+		})
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	result := runBacktest(spreads, scores, games, strategy, *lookback, *vig)
+	if result.Bets == 0 {
+		fmt.Println("沒有可以回測的比賽，先用 backfill 和 spread record 存資料")
+		return
+	}
+	fmt.Println("策略", *name)
+	fmt.Println(result.Text())
+}
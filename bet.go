@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"example/scanNBA/odds"
+)
+
+//下注種類
+const (
+	BetSpread    = "spread"
+	BetMoneyline = "moneyline"
+	BetTotal     = "total"
+)
+
+//下注結果，讓分和大小分可能走盤，0.25 結尾的盤口可能贏半或輸半
+const (
+	BetWin      = odds.Win
+	BetHalfWin  = odds.HalfWin
+	BetPush     = odds.Push
+	BetHalfLoss = odds.HalfLoss
+	BetLoss     = odds.Loss
+)
+
+//一筆下注，用 nba.com 的 gameId 對應比賽
+type Bet struct {
+	ID       uint64    `json:"id"`
+	GameID   string    `json:"gameId"`
+	Date     string    `json:"date"`
+	AwayTeam string    `json:"awayTeam"`
+	HomeTeam string    `json:"homeTeam"`
+	Type     string    `json:"type"`
+	Side     string    `json:"side"` //home、away、over、under
+	Line     float64   `json:"line"` //下注那一方的讓分，或大小分的總分
+	Odds     float64   `json:"odds"` //美式賠率，-110、+150
+	Stake    float64   `json:"stake"`
+	Note     string    `json:"note,omitempty"`
+	PlacedAt time.Time `json:"placedAt"`
+
+	Settled   bool    `json:"settled"`
+	AwayScore int     `json:"awayScore,omitempty"`
+	HomeScore int     `json:"homeScore,omitempty"`
+	Result    string  `json:"result,omitempty"`
+	Profit    float64 `json:"profit"`
+}
+
+//下注的隊伍，大小分回傳空白
+func (b Bet) Team() string {
+	switch b.Side {
+	case "home":
+		return b.HomeTeam
+	case "away":
+		return b.AwayTeam
+	}
+	return ""
+}
+
+//用最終比分結算，回傳結果和盈虧，賠率看不懂時回傳錯誤，不能當作走盤
+func settleBet(b Bet, homeScore, awayScore int) (result string, profit float64, err error) {
+	var line, margin float64
+	switch b.Type {
+	case BetSpread, BetMoneyline:
+		margin = float64(homeScore - awayScore)
+		if b.Side == "away" {
+			margin = -margin
+		}
+		if b.Type == BetSpread {
+			line = b.Line
+		}
+	case BetTotal:
+		line, margin = -b.Line, float64(homeScore+awayScore)
+		if b.Side == "under" {
+			line, margin = -line, -margin
+		}
+	}
+
+	price, err := odds.FromAmerican(b.Odds)
+	if err != nil {
+		return "", 0, err
+	}
+	result = odds.SettleHandicap(line, margin)
+	return result, odds.Profit(result, b.Stake, price), nil
+}
+
+func betLabel(result string) string {
+	switch result {
+	case BetWin:
+		return "贏"
+	case BetHalfWin:
+		return "贏半"
+	case BetPush:
+		return "走"
+	case BetHalfLoss:
+		return "輸半"
+	case BetLoss:
+		return "輸"
+	}
+	return "未結算"
+}
+
+//eg: "#3 2023-01-15 湖人 @ 勇士(主) 讓分 主隊 -5.5 -110 100 贏 +90.9"
+func (b Bet) Text(teamMap map[string]string) string {
+	msg := fmt.Sprintf("#%d %s %s @ %s(主) %s", b.ID, b.Date, chineseName(teamMap, b.AwayTeam), chineseName(teamMap, b.HomeTeam), betTypeLabel(b.Type))
+	switch b.Type {
+	case BetSpread:
+		line := odds.FormatHandicap(b.Line)
+		if b.Line > 0 {
+			line = "+" + line
+		}
+		msg = msg + " " + betSideLabel(b.Side) + " " + line
+	case BetTotal:
+		msg = msg + " " + betSideLabel(b.Side) + " " + odds.FormatHandicap(b.Line)
+	default:
+		msg = msg + " " + betSideLabel(b.Side)
+	}
+	msg = msg + fmt.Sprintf(" %+.0f %.2f %s", b.Odds, b.Stake, betLabel(b.Result))
+	if b.Settled {
+		msg = msg + fmt.Sprintf(" %+.2f", b.Profit)
+	}
+	return msg
+}
+
+func betTypeLabel(t string) string {
+	switch t {
+	case BetSpread:
+		return "讓分"
+	case BetMoneyline:
+		return "獨贏"
+	case BetTotal:
+		return "大小分"
+	}
+	return t
+}
+
+func betSideLabel(side string) string {
+	switch side {
+	case "home":
+		return "主隊"
+	case "away":
+		return "客隊"
+	case "over":
+		return "大"
+	case "under":
+		return "小"
+	}
+	return side
+}
+
+//scanNBA bet add|settle|list|report|export
+func betCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("用法: scanNBA bet add|settle|list|report|export")
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "add":
+		err = betAdd(args[1:])
+	case "settle":
+		err = betSettle(args[1:])
+	case "list":
+		err = betList(args[1:])
+	case "report":
+		err = betReport(args[1:])
+	case "export":
+		err = betExport(args[1:])
+	default:
+		fmt.Println("未知的指令: bet " + args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+//scanNBA bet add -game 0022200700 -type spread -side home -line -5.5 [-odds -110] [-format american] -stake 100 [-note ...]
+func betAdd(args []string) error {
+	fs := flag.NewFlagSet("bet add", flag.ExitOnError)
+	gameID := fs.String("game", "", "nba.com 的 gameId")
+	betType := fs.String("type", BetSpread, "spread、moneyline 或 total")
+	side := fs.String("side", "", "home、away，大小分是 over、under")
+	line := fs.String("line", "0", "下注那一方的讓分，大小分是總分，可以用 0/0.5 這種亞洲盤")
+	price := fs.String("odds", "-110", "賠率，可以是美式 -110、歐洲盤 1.91 或香港盤 0.91")
+	format := fs.String("format", "", "賠率格式 american、decimal、hk，空白表示自動判斷")
+	stake := fs.Float64("stake", 0, "下注金額")
+	note := fs.String("note", "", "備註")
+	fs.Parse(args)
+
+	switch *betType {
+	case BetSpread, BetMoneyline:
+		if *side != "home" && *side != "away" {
+			return fmt.Errorf("-side 要是 home 或 away")
+		}
+	case BetTotal:
+		if *side != "over" && *side != "under" {
+			return fmt.Errorf("-side 要是 over 或 under")
+		}
+	default:
+		return fmt.Errorf("未知的下注種類: %s", *betType)
+	}
+	if *stake <= 0 {
+		return fmt.Errorf("-stake 要大於 0")
+	}
+	handicap, err := odds.ParseHandicap(*line)
+	if err != nil {
+		return err
+	}
+	p, err := odds.Parse(*price, *format)
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(s *Store) error {
+		g, date, ok, err := s.Game(*gameID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("資料庫內找不到比賽 %s，先跑一次 scanNBA 或 backfill", *gameID)
+		}
+
+		bet := Bet{
+			GameID:   *gameID,
+			Date:     date,
+			AwayTeam: g.AwayTeam.FullName(),
+			HomeTeam: g.HomeTeam.FullName(),
+			Type:     *betType,
+			Side:     *side,
+			Line:     handicap,
+			Odds:     math.Round(p.American()*100) / 100,
+			Stake:    *stake,
+			Note:     *note,
+			PlacedAt: time.Now(),
+		}
+		if err := s.SaveBet(&bet); err != nil {
+			return err
+		}
+		fmt.Println(bet.Text(TeamInit()))
+		return nil
+	})
+}
+
+//scanNBA bet settle
+//
+//把還沒結算的下注用最終比分結算，資料庫沒有比分就重新抓那天的 daily.json
+//某天的賽程抓不到或某筆下注不能結算時印出來，繼續處理其他的
+func betSettle(args []string) error {
+	fs := flag.NewFlagSet("bet settle", flag.ExitOnError)
+	fs.Parse(args)
+
+	teamMap := TeamInit()
+	return withStore(func(s *Store) error {
+		bets, err := s.Bets()
+		if err != nil {
+			return err
+		}
+
+		fetched := make(map[string]bool)
+		for _, bet := range bets {
+			if bet.Settled {
+				continue
+			}
+
+			score, ok, err := s.Score(bet.GameID)
+			if err != nil {
+				return err
+			}
+			if !ok && !fetched[bet.Date] {
+				fetched[bet.Date] = true
+				schedule, err := loadSchedule(bet.Date)
+				if err != nil {
+					fmt.Println(bet.Date, "讀取賽程失敗:", err)
+					continue
+				}
+				if err := s.SaveSchedule(bet.Date, schedule); err != nil {
+					return err
+				}
+				if score, ok, err = s.Score(bet.GameID); err != nil {
+					return err
+				}
+			}
+			if !ok {
+				continue
+			}
+
+			result, profit, err := settleBet(bet, score.HomeScore, score.AwayScore)
+			if err != nil {
+				fmt.Println("下注", bet.ID, "不能結算:", err)
+				continue
+			}
+			bet.Settled = true
+			bet.AwayScore = score.AwayScore
+			bet.HomeScore = score.HomeScore
+			bet.Result, bet.Profit = result, profit
+			if err := s.SaveBet(&bet); err != nil {
+				return err
+			}
+			fmt.Println(bet.Text(teamMap))
+		}
+		return nil
+	})
+}
+
+//所有下注，有 -season 時只回傳那一季的
+func loadBets() (bets []Bet, err error) {
+	from, to, err := seasonRange()
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Bet
+	err = withStore(func(s *Store) (err error) {
+		all, err = s.Bets()
+		return err
+	})
+	for _, b := range all {
+		if inRange(b.Date, from, to) {
+			bets = append(bets, b)
+		}
+	}
+	return bets, err
+}
+
+//scanNBA bet list [-open] [-season 2022-23]
+func betList(args []string) error {
+	fs := flag.NewFlagSet("bet list", flag.ExitOnError)
+	open := fs.Bool("open", false, "只列出還沒結算的")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	bets, err := loadBets()
+	if err != nil {
+		return err
+	}
+	teamMap := TeamInit()
+	for _, b := range bets {
+		if *open && b.Settled {
+			continue
+		}
+		fmt.Println(b.Text(teamMap))
+	}
+	return nil
+}
+
+//一組下注的盈虧
+type BetSummary struct {
+	Key    string
+	Bets   int
+	Wins   int
+	Losses int
+	Pushes int
+	Staked float64
+	Profit float64
+}
+
+func (s *BetSummary) add(b Bet) {
+	s.Bets++
+	s.Staked = s.Staked + b.Stake
+	s.Profit = s.Profit + b.Profit
+	switch b.Result {
+	case BetWin, BetHalfWin:
+		s.Wins++
+	case BetLoss, BetHalfLoss:
+		s.Losses++
+	case BetPush:
+		s.Pushes++
+	}
+}
+
+func (s BetSummary) ROI() float64 {
+	if s.Staked == 0 {
+		return 0
+	}
+	return s.Profit / s.Staked * 100
+}
+
+//已結算的下注依 key 分組，大小分依隊伍分組時兩隊都算
+func summarizeBets(bets []Bet, by string) (result []BetSummary) {
+	groups := make(map[string]*BetSummary)
+	for _, b := range bets {
+		if !b.Settled {
+			continue
+		}
+
+		var keys []string
+		switch by {
+		case "team":
+			if team := b.Team(); team != "" {
+				keys = []string{team}
+			} else {
+				keys = []string{b.AwayTeam, b.HomeTeam}
+			}
+		case "type":
+			keys = []string{b.Type}
+		case "month":
+			keys = []string{b.Date[:7]}
+		default:
+			keys = []string{"全部"}
+		}
+
+		for _, key := range keys {
+			if groups[key] == nil {
+				groups[key] = &BetSummary{Key: key}
+			}
+			groups[key].add(b)
+		}
+	}
+
+	for _, v := range groups {
+		result = append(result, *v)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+//scanNBA bet report [-by team|type|month] [-season 2022-23]
+func betReport(args []string) error {
+	fs := flag.NewFlagSet("bet report", flag.ExitOnError)
+	by := fs.String("by", "month", "team、type 或 month")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	if *by != "team" && *by != "type" && *by != "month" {
+		return fmt.Errorf("-by 要是 team、type 或 month")
+	}
+
+	bets, err := loadBets()
+	if err != nil {
+		return err
+	}
+
+	teamMap := TeamInit()
+	for _, v := range append(summarizeBets(bets, *by), summarizeBets(bets, "")...) {
+		key := v.Key
+		switch *by {
+		case "team":
+			key = chineseName(teamMap, key)
+		case "type":
+			key = betTypeLabel(key)
+		}
+		fmt.Printf("%-10s %3d 注 %d-%d-%d 下注 %.2f 盈虧 %+.2f ROI %.1f%%\n", key, v.Bets, v.Wins, v.Losses, v.Pushes, v.Staked, v.Profit, v.ROI())
+	}
+	return nil
+}
+
+//scanNBA bet export [-o bets.csv] [-season 2022-23]
+func betExport(args []string) error {
+	fs := flag.NewFlagSet("bet export", flag.ExitOnError)
+	output := fs.String("o", "bets.csv", "輸出的 CSV 檔案，- 表示輸出到 stdout")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	bets, err := loadBets()
+	if err != nil {
+		return err
+	}
+
+	f := os.Stdout
+	if *output != "-" {
+		if f, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"id", "gameId", "date", "awayTeam", "homeTeam", "type", "side", "line", "odds", "stake", "result", "profit", "awayScore", "homeScore", "note"})
+	for _, b := range bets {
+		var scoreAway, scoreHome string
+		if b.Settled {
+			scoreAway, scoreHome = strconv.Itoa(b.AwayScore), strconv.Itoa(b.HomeScore)
+		}
+		w.Write([]string{
+			strconv.FormatUint(b.ID, 10), b.GameID, b.Date, b.AwayTeam, b.HomeTeam, b.Type, b.Side,
+			strconv.FormatFloat(b.Line, 'f', -1, 64),
+			strconv.FormatFloat(b.Odds, 'f', -1, 64),
+			strconv.FormatFloat(b.Stake, 'f', -1, 64),
+			b.Result,
+			strconv.FormatFloat(b.Profit, 'f', 2, 64),
+			scoreAway, scoreHome, b.Note,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if *output != "-" {
+		fmt.Println("匯出", len(bets), "筆到", *output)
+	}
+	return nil
+}
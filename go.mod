@@ -2,9 +2,13 @@ module example/scanNBA
 
 go 1.18
 
-require github.com/PuerkitoBio/goquery v1.8.1
+require (
+	github.com/PuerkitoBio/goquery v1.8.1
+	go.etcd.io/bbolt v1.3.7
+)
 
 require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	golang.org/x/net v0.7.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
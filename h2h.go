@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+//兩隊之前的一次交手
+type Meeting struct {
+	Date       string  `json:"date"` //美東日期
+	AwayTeam   string  `json:"awayTeam"`
+	HomeTeam   string  `json:"homeTeam"`
+	AwayScore  int     `json:"awayScore"`
+	HomeScore  int     `json:"homeScore"`
+	Line       float64 `json:"line"` //主隊讓分
+	HasLine    bool    `json:"hasLine"`
+	HomeResult string  `json:"homeResult,omitempty"`
+	AwayResult string  `json:"awayResult,omitempty"`
+}
+
+//這一季和上一季的 titan007 比賽，包含季後賽，依時間排序
+//季後賽還沒開始時沒有檔案，讀不到只印出來
+func loadH2HMatches(season string) ([]TitanMatch, error) {
+	var result []TitanMatch
+	for _, season := range []string{previousSeason(season), season} {
+		for _, kind := range []int{titanRegular, titanPlayoffs} {
+			matches, err := loadKindMatches(season, kind)
+			if err != nil && kind == titanPlayoffs {
+				fmt.Println(season, "titan007 季後賽資料錯誤:", err)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, matches...)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+//titan007 的比賽時間是北京時間，比美東日期晚半天到一天
+func sameMeeting(m TitanMatch, date, home, away string) bool {
+	if !sameTeam(m.Home, home) || !sameTeam(m.Away, away) {
+		return false
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, beijing)
+	if err != nil {
+		return false
+	}
+	diff := m.Time.Sub(day)
+	return diff > -12*time.Hour && diff < 48*time.Hour
+}
+
+//兩隊這一季和上一季的交手，比分以資料庫(backfill)為主，titan007 補上盤口和資料庫沒有的比賽
+func headToHead(scores []FinalScore, matches []TitanMatch, a, b string, since, before string) (result []Meeting) {
+	used := make(map[int]bool)
+
+	for _, s := range scores {
+		if s.Date < since || s.Date >= before {
+			continue
+		}
+		if !(sameTeam(s.HomeTeam, a) && sameTeam(s.AwayTeam, b)) && !(sameTeam(s.HomeTeam, b) && sameTeam(s.AwayTeam, a)) {
+			continue
+		}
+
+		meeting := Meeting{
+			Date:      s.Date,
+			AwayTeam:  s.AwayTeam,
+			HomeTeam:  s.HomeTeam,
+			AwayScore: s.AwayScore,
+			HomeScore: s.HomeScore,
+		}
+		for _, m := range matches {
+			if !used[m.ID] && sameMeeting(m, s.Date, s.HomeTeam, s.AwayTeam) {
+				used[m.ID] = true
+				meeting.Line, meeting.HasLine = -m.Line, m.HasLine
+				break
+			}
+		}
+		result = append(result, meeting)
+	}
+
+	for _, m := range matches {
+		if used[m.ID] || !m.Finished {
+			continue
+		}
+		if !(sameTeam(m.Home, a) && sameTeam(m.Away, b)) && !(sameTeam(m.Home, b) && sameTeam(m.Away, a)) {
+			continue
+		}
+
+		//北京時間換回美東日期
+		date := gameDate(m.Time)
+		if date < since || date >= before {
+			continue
+		}
+		result = append(result, Meeting{
+			Date:      date,
+			AwayTeam:  m.Away,
+			HomeTeam:  m.Home,
+			AwayScore: m.AwayScore,
+			HomeScore: m.HomeScore,
+			Line:      -m.Line,
+			HasLine:   m.HasLine,
+		})
+	}
+
+	for i := range result {
+		if result[i].HasLine {
+			result[i].HomeResult, result[i].AwayResult = settleSpread(result[i].Line, result[i].HomeScore, result[i].AwayScore)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date > result[j].Date
+	})
+	return result
+}
+
+//eg: "2023-01-15 湖人 110 @ 勇士(主) 105  主隊 -5.5 客贏 主輸"
+func (m Meeting) Text(teamMap map[string]string) string {
+	msg := fmt.Sprintf("%s %s %d @ %s(主) %d", m.Date, chineseName(teamMap, m.AwayTeam), m.AwayScore, chineseName(teamMap, m.HomeTeam), m.HomeScore)
+	if !m.HasLine {
+		return msg + "  無盤口"
+	}
+	return msg + fmt.Sprintf("  主隊 %+.1f 客%s 主%s", m.Line, atsLabel(m.AwayResult), atsLabel(m.HomeResult))
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//球員的影響力，CSV 匯入或從存下來的 box score 算出來
+type PlayerImpact struct {
+	PlayerID  string    `json:"playerId"`
+	Player    string    `json:"player"`
+	Team      string    `json:"team"`
+	Minutes   float64   `json:"minutes"` //平均上場時間
+	Usage     float64   `json:"usage"`   //使用率(%)，沒有就是 0
+	Rating    float64   `json:"rating"`  //自訂評分，有給就直接用
+	Source    string    `json:"source"`  //csv 或 boxscore
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+//影響力分數，大約 0~10，明星球員接近 10
+func (p PlayerImpact) Score() float64 {
+	if p.Rating > 0 {
+		return p.Rating
+	}
+	score := p.Minutes / 48 * 10
+	if p.Usage > 0 {
+		score = score * p.Usage / 20
+	}
+	return score
+}
+
+//缺陣的可能性，用狀態和說明判斷，和 sortComment 的分類一致
+func absenceWeight(v Injury) float64 {
+	status := strings.ToLower(v.Status)
+	switch {
+	case status == "out":
+		return 1
+	case strings.Contains(status, "doubtful"):
+		return 0.75
+	case strings.Contains(status, "questionable"):
+		return 0.5
+	case strings.Contains(status, "probable"):
+		return 0.1
+	}
+
+	switch sortComment("", v.Comment) {
+	case "不上/":
+		return 1
+	case "可能不上/":
+		return 0.6
+	case "可能會上/":
+		return 0.1
+	}
+	return 0.5
+}
+
+//某個傷兵的影響，沒有資料的球員算 0，impacts 的 key 是球員編號
+func injuryImpact(impacts map[string]PlayerImpact, v Injury) float64 {
+	return impacts[v.PlayerID].Score() * absenceWeight(v)
+}
+
+//全隊缺陣的影響力總和
+func missingImpact(impacts map[string]PlayerImpact, injuries []Injury) (result float64) {
+	for _, v := range injuries {
+		result = result + injuryImpact(impacts, v)
+	}
+	return result
+}
+
+//影響大的傷兵排前面
+func sortByImpact(impacts map[string]PlayerImpact, injuries []Injury) {
+	sort.SliceStable(injuries, func(i, j int) bool {
+		return injuryImpact(impacts, injuries[i]) > injuryImpact(impacts, injuries[j])
+	})
+}
+
+//讀取資料庫內的影響力，讀不到就當作沒有資料
+func loadImpacts() map[string]PlayerImpact {
+	var impacts map[string]PlayerImpact
+	if err := withStore(func(s *Store) (err error) {
+		impacts, err = s.Impacts()
+		return err
+	}); err != nil {
+		fmt.Println("讀取球員影響力失敗:", err)
+	}
+	return impacts
+}
+
+//scanNBA impact import file.csv | refresh | list
+func impactCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("用法: scanNBA impact import file.csv|refresh|list")
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "import":
+		if len(args) < 2 {
+			fmt.Println("用法: scanNBA impact import file.csv")
+			os.Exit(2)
+		}
+		err = impactImport(args[1])
+	case "refresh":
+		err = impactRefresh(args[1:])
+	case "list":
+		err = impactList()
+	default:
+		fmt.Println("未知的指令: impact " + args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+//CSV 第一行是欄位名稱，player 必填，其他欄位可以省略
+//
+//	player,team,minutes,usage,rating
+//	LeBron James,Los Angeles Lakers,35.5,31.2,
+func impactImport(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["player"]; !ok {
+		return fmt.Errorf("CSV 缺少 player 欄位")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	number := func(row []string, name string) (float64, error) {
+		v := field(row, name)
+		if v == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(v, 64)
+	}
+
+	var impacts []PlayerImpact
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		impact := PlayerImpact{Player: field(row, "player"), Team: field(row, "team"), Source: "csv", UpdatedAt: time.Now()}
+		if impact.Player == "" {
+			continue
+		}
+		if impact.Minutes, err = number(row, "minutes"); err != nil {
+			return fmt.Errorf("第 %d 行 minutes: %v", line, err)
+		}
+		if impact.Usage, err = number(row, "usage"); err != nil {
+			return fmt.Errorf("第 %d 行 usage: %v", line, err)
+		}
+		if impact.Rating, err = number(row, "rating"); err != nil {
+			return fmt.Errorf("第 %d 行 rating: %v", line, err)
+		}
+		impacts = append(impacts, impact)
+	}
+
+	if err := withStore(func(s *Store) error {
+		return s.SaveImpacts(impacts)
+	}); err != nil {
+		return err
+	}
+	fmt.Println("匯入", len(impacts), "位球員")
+	return nil
+}
+
+//領先球員的名字，displayName 沒有就用姓名組起來
+func (l GameLeader) Name() string {
+	if l.Profile.DisplayName != "" {
+		return l.Profile.DisplayName
+	}
+	return strings.TrimSpace(l.Profile.FirstName + " " + l.Profile.LastName)
+}
+
+//scanNBA impact refresh [-days 30] [-season 2022-23]
+//
+//用資料庫內最近幾天(有 -season 時是那一季)的 box score 更新影響力，daily.json 只有得分、助攻、籃板的領先球員，
+//所以只會更新到這些球員，而且只有上場時間(Score 用上場時間換算)，CSV 匯入的資料不會被蓋掉
+func impactRefresh(args []string) error {
+	fs := flag.NewFlagSet("impact refresh", flag.ExitOnError)
+	days := fs.Int("days", 30, "看最近幾天的比賽")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	from, to, err := seasonRange()
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		from = gameDate(time.Now().AddDate(0, 0, -*days))
+	}
+
+	type total struct {
+		name, team string
+		games      int
+		minutes    float64
+	}
+	totals := make(map[string]*total)
+
+	return withStore(func(s *Store) error {
+		existing, err := s.Impacts()
+		if err != nil {
+			return err
+		}
+		players, err := s.Players()
+		if err != nil {
+			return err
+		}
+
+		err = s.EachGame(func(date string, g Game) error {
+			if !inRange(date, from, to) || !g.Final() {
+				return nil
+			}
+			for _, t := range []GameTeam{g.AwayTeam, g.HomeTeam} {
+				seen := make(map[string]bool)
+				for _, leader := range t.Leaders() {
+					name := leader.Name()
+					key := normalizeName(name)
+					if key == "" || seen[key] {
+						continue
+					}
+					seen[key] = true
+
+					if totals[key] == nil {
+						totals[key] = &total{}
+					}
+					totals[key].name = name
+					totals[key].team = t.FullName()
+					totals[key].games++
+					totals[key].minutes = totals[key].minutes + float64(leader.StatTotal.Mins)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		var impacts []PlayerImpact
+		for _, t := range totals {
+			if id, ok := players.Lookup(t.name, t.team); ok && existing[id].Source == "csv" {
+				continue
+			}
+			impacts = append(impacts, PlayerImpact{
+				Player:    t.name,
+				Team:      t.team,
+				Minutes:   t.minutes / float64(t.games),
+				Source:    "boxscore",
+				UpdatedAt: time.Now(),
+			})
+		}
+
+		if err := s.SaveImpacts(impacts); err != nil {
+			return err
+		}
+		fmt.Println("更新", len(impacts), "位球員")
+		return nil
+	})
+}
+
+func impactList() error {
+	impacts := loadImpacts()
+
+	var list []PlayerImpact
+	for _, v := range impacts {
+		list = append(list, v)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Score() > list[j].Score()
+	})
+
+	for _, v := range list {
+		fmt.Printf("%-25s %-25s %5.1f  (%s)\n", v.Player, v.Team, v.Score(), v.Source)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//傷兵名單的一筆資料
+type Injury struct {
+	Team    string `json:"team"`
+	Player  string `json:"player"`
+	Status  string `json:"status"`
+	Date    string `json:"date"` //來源上的更新日期，eg: "Feb 20"
+	Comment string `json:"comment"`
+
+	Source    string    `json:"source,omitempty"` //espn、cbs、nba
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+	Conflict  string    `json:"conflict,omitempty"` //其他來源不一樣的狀態，eg: "cbs: Questionable"
+	GameID    string    `json:"gameId,omitempty"`   //官方報告是每場比賽一份名單
+	PlayerID  string    `json:"playerId,omitempty"` //球員編號，不同來源的寫法對應到同一個人
+}
+
+//某個時間點的所有傷兵，用來和下一次比對
+type InjurySnapshot struct {
+	Time     time.Time `json:"time"`
+	Injuries []Injury  `json:"injuries"`
+}
+
+//兩次快照之間的變化
+type InjuryChange struct {
+	Type      string `json:"type"` //added, removed, status
+	Team      string `json:"team"`
+	Player    string `json:"player"`
+	PlayerID  string `json:"playerId,omitempty"`
+	OldStatus string `json:"oldStatus,omitempty"`
+	NewStatus string `json:"newStatus,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+const (
+	InjuryAdded   = "added"
+	InjuryRemoved = "removed"
+	InjuryStatus  = "status"
+)
+
+//一次抓下 ESPN 全聯盟的傷兵名單
+func fetchInjuries() (result []Injury, err error) {
+	res, err := http.Get("https://www.espn.com/nba/injuries")
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Find(".Table__league-injuries").Each(func(i int, s *goquery.Selection) {
+		team := s.Find(".injuries__teamName").Text()
+
+		s.Find(".Table__even").Each(func(ii int, g *goquery.Selection) {
+			injury := Injury{
+				Team:    team,
+				Player:  g.Find(".AnchorLink").Text(),
+				Status:  g.Find(".col-stat").Text(),
+				Date:    g.Find(".col-date").Text(),
+				Comment: g.Find(".col-desc").Text(),
+			}
+
+			if injury.Status != "" {
+				result = append(result, injury)
+			}
+		})
+	})
+
+	return result, nil
+}
+
+//比對隊名，ESPN 的快艇是 "LA Clippers"
+func sameTeam(a, b string) bool {
+	if a == "Los Angeles Clippers" {
+		a = "LA Clippers"
+	}
+	if b == "Los Angeles Clippers" {
+		b = "LA Clippers"
+	}
+	return strings.EqualFold(a, b)
+}
+
+//取出某隊的傷兵
+func teamInjuries(injuries []Injury, searchTeam string) (result []Injury) {
+	for _, v := range injuries {
+		if sameTeam(v.Team, searchTeam) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+//有球員編號就用編號比對，換隊或來源的寫法不同都還是同一個人
+func injuryKey(v Injury) string {
+	if v.PlayerID != "" {
+		return v.PlayerID
+	}
+	return strings.ToLower(v.Team + "|" + v.Player)
+}
+
+//比對前後兩次的傷兵名單，依隊伍和球員排序
+func diffInjuries(prev, cur []Injury) (result []InjuryChange) {
+	prevMap := make(map[string]Injury)
+	for _, v := range prev {
+		prevMap[injuryKey(v)] = v
+	}
+
+	curMap := make(map[string]Injury)
+	for _, v := range cur {
+		curMap[injuryKey(v)] = v
+
+		old, ok := prevMap[injuryKey(v)]
+		switch {
+		case !ok:
+			result = append(result, InjuryChange{Type: InjuryAdded, Team: v.Team, Player: v.Player, PlayerID: v.PlayerID, NewStatus: v.Status, Comment: v.Comment})
+		case !strings.EqualFold(old.Status, v.Status):
+			result = append(result, InjuryChange{Type: InjuryStatus, Team: v.Team, Player: v.Player, PlayerID: v.PlayerID, OldStatus: old.Status, NewStatus: v.Status, Comment: v.Comment})
+		}
+	}
+
+	for _, v := range prev {
+		if _, ok := curMap[injuryKey(v)]; !ok {
+			result = append(result, InjuryChange{Type: InjuryRemoved, Team: v.Team, Player: v.Player, PlayerID: v.PlayerID, OldStatus: v.Status})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Team != result[j].Team {
+			return result[i].Team < result[j].Team
+		}
+		return result[i].Player < result[j].Player
+	})
+
+	return result
+}
+
+//只留下 teams 內隊伍的變化
+func filterInjuryChanges(changes []InjuryChange, teams []string) (result []InjuryChange) {
+	for _, c := range changes {
+		for _, team := range teams {
+			if sameTeam(c.Team, team) {
+				result = append(result, c)
+				break
+			}
+		}
+	}
+	return result
+}
+
+//變化的中文說明，eg: "湖人 LeBron James: Day-To-Day -> Out"
+func (c InjuryChange) Text(teamMap map[string]string) string {
+	team := chineseName(teamMap, c.Team)
+	switch c.Type {
+	case InjuryAdded:
+		return team + " " + c.Player + ": 新增 " + c.NewStatus
+	case InjuryRemoved:
+		return team + " " + c.Player + ": 移出名單 (原本 " + c.OldStatus + ")"
+	default:
+		return team + " " + c.Player + ": " + c.OldStatus + " -> " + c.NewStatus
+	}
+}
+
+func loadInjurySnapshot(path string) (snapshot InjurySnapshot, err error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(body, &snapshot)
+	return snapshot, err
+}
+
+func saveInjurySnapshot(path string, snapshot InjurySnapshot) error {
+	body, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, body, 0644)
+}
+
+//抓所有來源最新的傷兵名單合併，和上次的快照比對後存成新的快照
+//第一次執行沒有舊快照，不會有任何變化
+func updateInjurySnapshot(path string) ([]InjuryChange, error) {
+	cur, err := fetchAllInjuries(injurySources())
+	if err != nil {
+		return nil, err
+	}
+
+	prev, err := loadInjurySnapshot(path)
+	first := os.IsNotExist(err)
+	if err != nil && !first {
+		return nil, err
+	}
+	//新的球員登記後才有編號，舊的快照也補上，比對時才對得起來；舊的先登記，換隊的球員最後是新的隊伍
+	registerInjuries(prev.Injuries, cur)
+
+	snapshot := InjurySnapshot{Time: time.Now(), Injuries: cur}
+	if err := saveInjurySnapshot(path, snapshot); err != nil {
+		return nil, err
+	}
+	saveToStore(func(s *Store) error {
+		return s.SaveInjurySnapshot(snapshot)
+	})
+
+	if first {
+		return nil, nil
+	}
+	return diffInjuries(prev.Injuries, cur), nil
+}
+
+//scanNBA injuries [-snapshot file] [-slate] [-pdf file] [-season 2022-23]
+//
+//比對這次和上次的傷兵名單，有變化就印出並送通知；-pdf 只解析下載好的官方傷兵報告
+func injuriesCmd(args []string) {
+	fs := flag.NewFlagSet("injuries", flag.ExitOnError)
+	snapshotPath := fs.String("snapshot", "injury-snapshot.json", "傷兵快照檔案")
+	slateOnly := fs.Bool("slate", false, "只通知今天有比賽的隊伍")
+	pdfPath := fs.String("pdf", "", "官方傷兵報告 PDF 檔案")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	//傷兵名單只有現在的，-pdf 是下載好的檔案，哪一季都可以
+	if err := checkCurrentSeason("injuries"); err != nil && *pdfPath == "" {
+		fmt.Println(err)
+		return
+	}
+
+	if *pdfPath != "" {
+		body, err := ioutil.ReadFile(*pdfPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		rows, err := parseOfficialReport(body)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printOfficialReport(rows)
+		return
+	}
+
+	//賽程要在更新快照前讀，讀不到時快照不動，變化留到下次
+	report := Report{Date: gameDate(time.Now())}
+	var schedule Schedule
+	if *slateOnly {
+		var err error
+		if schedule, err = getSchedule(report.Date); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	changes, err := updateInjurySnapshot(*snapshotPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *slateOnly {
+		changes = filterInjuryChanges(changes, slateTeams(schedule))
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("傷兵名單沒有變化")
+		return
+	}
+
+	report.InjuryChanges = changes
+	fmt.Println(injuryChangesText(changes))
+	notifyAll(notifiersFromEnv(), &report)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//傷兵名單的來源
+type InjurySource interface {
+	Name() string
+	Fetch() ([]Injury, error)
+}
+
+type espnInjuries struct{}
+
+func (espnInjuries) Name() string {
+	return "espn"
+}
+
+func (s espnInjuries) Fetch() ([]Injury, error) {
+	injuries, err := fetchInjuries()
+	if err != nil {
+		return nil, err
+	}
+	for i := range injuries {
+		injuries[i].Source = s.Name()
+		injuries[i].UpdatedAt = injuryDate(injuries[i].Date, time.Now())
+	}
+	return injuries, nil
+}
+
+//CBS Sports 的傷兵表格，狀態是一段說明，eg: "Expected to be out until at least Feb 23"
+type cbsInjuries struct{}
+
+func (cbsInjuries) Name() string {
+	return "cbs"
+}
+
+func (s cbsInjuries) Fetch() (result []Injury, err error) {
+	res, err := http.Get("https://www.cbssports.com/nba/injuries/")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Find(".TableBaseWrapper").Each(func(i int, t *goquery.Selection) {
+		name := t.Find(".TeamName").First()
+		href, _ := name.Find("a").Attr("href")
+		team := cbsTeam(href, strings.TrimSpace(name.Text()))
+
+		t.Find("tr.TableBase-bodyTr").Each(func(ii int, row *goquery.Selection) {
+			cells := row.Find("td")
+			if cells.Length() < 5 {
+				return
+			}
+			status := strings.TrimSpace(cells.Eq(4).Text())
+			injury := Injury{
+				Team:    team,
+				Player:  strings.TrimSpace(cells.Eq(0).Find(".CellPlayerName--long").Text()),
+				Status:  cbsStatus(status),
+				Date:    strings.TrimSpace(cells.Eq(2).Text()),
+				Comment: strings.TrimSpace(cells.Eq(3).Text()) + " - " + status,
+				Source:  s.Name(),
+			}
+			if injury.Player == "" {
+				injury.Player = strings.TrimSpace(cells.Eq(0).Text())
+			}
+			injury.UpdatedAt = injuryDate(injury.Date, time.Now())
+
+			if injury.Player != "" && status != "" {
+				result = append(result, injury)
+			}
+		})
+	})
+
+	return result, nil
+}
+
+//CBS 和 nba.com 不一樣的縮寫
+var cbsAbbr = map[string]string{
+	"GS":   "GSW",
+	"NY":   "NYK",
+	"NO":   "NOP",
+	"SA":   "SAS",
+	"PHO":  "PHX",
+	"UTAH": "UTA",
+	"WSH":  "WAS",
+}
+
+//CBS 的 .TeamName 只有城市(eg: "Atlanta")，用連結 "/nba/teams/ATL/atlanta-hawks/" 的縮寫換成全名，
+//縮寫對不到再比對網址後面的隊名，都對不到就用原本的文字
+func cbsTeam(href, text string) string {
+	parts := strings.Split(strings.Trim(href, "/"), "/")
+	for i, p := range parts {
+		if p != "teams" || i+1 >= len(parts) {
+			continue
+		}
+		abbr := strings.ToUpper(parts[i+1])
+		if v, ok := cbsAbbr[abbr]; ok {
+			abbr = v
+		}
+		abbrMap := TeamAbbrInit()
+		if team, ok := abbrMap[abbr]; ok {
+			return team
+		}
+		if i+2 < len(parts) {
+			for _, team := range abbrMap {
+				if strings.EqualFold(strings.ReplaceAll(team, " ", "-"), parts[i+2]) {
+					return team
+				}
+			}
+		}
+	}
+	return text
+}
+
+//CBS 的說明換成和 ESPN 一樣的狀態
+func cbsStatus(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.Contains(lower, "game time decision"):
+		return "Questionable"
+	case strings.Contains(lower, "doubtful"):
+		return "Doubtful"
+	case strings.Contains(lower, "questionable"):
+		return "Questionable"
+	case strings.Contains(lower, "probable"):
+		return "Probable"
+	case strings.Contains(lower, "out"):
+		return "Out"
+	}
+	return "Day-To-Day"
+}
+
+//"Feb 20" 或 "Sat, Feb 18" 換成時間，沒有年份就當作最近一次的那一天
+func injuryDate(s string, now time.Time) time.Time {
+	if i := strings.Index(s, ","); i >= 0 {
+		s = s[i+1:]
+	}
+	t, err := time.Parse("Jan 2", strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	t = time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if t.After(now.AddDate(0, 0, 1)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+//要用的來源，可以用 SCANNBA_INJURY_SOURCES 改，eg: "nba,espn,cbs"，順序就是同一天更新時的優先順序
+//官方報告(nba)要往前試好幾個網址，預設不開
+func injurySources() (result []InjurySource) {
+	names := splitEnv("SCANNBA_INJURY_SOURCES")
+	if len(names) == 0 {
+		names = []string{"espn", "cbs"}
+	}
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "espn":
+			result = append(result, espnInjuries{})
+		case "cbs":
+			result = append(result, cbsInjuries{})
+		case "nba":
+			result = append(result, officialInjuries{})
+		default:
+			fmt.Println("未知的傷兵來源:", name)
+		}
+	}
+	return result
+}
+
+//抓所有來源再合併，有一個來源成功就好
+func fetchAllInjuries(sources []InjurySource) ([]Injury, error) {
+	var (
+		sets [][]Injury
+		errs []string
+	)
+	for _, s := range sources {
+		injuries, err := s.Fetch()
+		if err != nil {
+			fmt.Println(s.Name(), "傷兵名單錯誤:", err)
+			errs = append(errs, s.Name()+": "+err.Error())
+			continue
+		}
+		sets = append(sets, injuries)
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("所有傷兵來源都失敗: %s", strings.Join(errs, "; "))
+	}
+	identifyInjuries(sets...)
+	return mergeInjuries(sets...), nil
+}
+
+//合併時比對用的隊伍
+func teamKey(team string) string {
+	if sameTeam(team, "LA Clippers") {
+		return "la clippers"
+	}
+	return strings.ToLower(strings.TrimSpace(team))
+}
+
+//傷兵狀態的大分類，用來判斷來源之間是不是不一致
+func statusClass(status string) string {
+	s := strings.ToLower(status)
+	for _, class := range []string{"out", "doubtful", "questionable", "probable"} {
+		if strings.Contains(s, class) {
+			return class
+		}
+	}
+	return "day-to-day"
+}
+
+//依球員編號合併多個來源，沒有編號就用隊伍和球員名稱，sets 的順序是優先順序
+//
+//狀態和說明用更新日期最新的來源，同一天就用前面的；名稱用最前面來源的寫法，快照比對才不會跳來跳去；
+//狀態分類不同時把其他來源的狀態記在 Conflict
+func mergeInjuries(sets ...[]Injury) (result []Injury) {
+	type group struct {
+		order    int
+		injuries []Injury
+	}
+	groups := make(map[string]*group)
+
+	for _, set := range sets {
+		for _, v := range set {
+			key := teamKey(v.Team) + "|" + normalizeName(v.Player)
+			if v.PlayerID != "" {
+				key = v.PlayerID
+			}
+			if groups[key] == nil {
+				groups[key] = &group{order: len(groups)}
+			}
+			groups[key].injuries = append(groups[key].injuries, v)
+		}
+	}
+
+	var list []*group
+	for _, g := range groups {
+		list = append(list, g)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].order < list[j].order
+	})
+
+	for _, g := range list {
+		best := g.injuries[0]
+		for _, v := range g.injuries[1:] {
+			if v.UpdatedAt.After(best.UpdatedAt) {
+				best = v
+			}
+		}
+		best.Team = g.injuries[0].Team
+		best.Player = g.injuries[0].Player
+
+		var conflicts []string
+		for _, v := range g.injuries {
+			if v.Source != best.Source && statusClass(v.Status) != statusClass(best.Status) {
+				conflicts = append(conflicts, v.Source+": "+v.Status)
+			}
+		}
+		best.Conflict = strings.Join(conflicts, ", ")
+
+		result = append(result, best)
+	}
+	return result
+}
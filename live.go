@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//scanNBA live [-interval 30s] [-date yyyy-mm-dd] [-season 2022-23]
+//
+//比賽進行中定時更新比分，顯示每節比分、比賽狀態和領先變換，比賽結束時印出結果
+//當天沒有比賽、全部比賽結束或收到 SIGINT/SIGTERM 就停止
+func liveCmd(args []string) {
+	fs := flag.NewFlagSet("live", flag.ExitOnError)
+	interval := fs.Duration("interval", 30*time.Second, "更新的間隔")
+	date := fs.String("date", gameDate(time.Now()), "比賽日期 yyyy-mm-dd")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	if err := checkSeasonDate(*date); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	board := newScoreboard()
+	for {
+		schedule, err := getSchedule(*date)
+		if err != nil {
+			board.events = append(board.events, time.Now().In(taipei).Format("15:04:05")+" "+err.Error())
+		} else {
+			board.update(*date, schedule)
+		}
+
+		fmt.Print("\033[H\033[2J")
+		fmt.Print(board.render(*date, schedule))
+
+		if err == nil && len(schedule.Payload.Date.Games) == 0 {
+			fmt.Println(*date, "沒有比賽")
+			return
+		}
+		if err == nil && board.allFinal(schedule) {
+			fmt.Println("今天的比賽都結束了")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+//記住上一次的狀態，用來產生事件
+type scoreboard struct {
+	teamMap map[string]string
+	status  map[string]string //gameId -> Boxscore.Status
+	leader  map[string]string //gameId -> 領先的隊伍，平手是空字串
+	changes map[string]int    //gameId -> 看到的領先變換次數
+	events  []string
+}
+
+func newScoreboard() *scoreboard {
+	return &scoreboard{
+		teamMap: TeamInit(),
+		status:  make(map[string]string),
+		leader:  make(map[string]string),
+		changes: make(map[string]int),
+	}
+}
+
+//比對上一次的比分，產生領先變換和比賽結束的事件
+func (b *scoreboard) update(date string, schedule Schedule) {
+	now := time.Now().In(taipei).Format("15:04:05")
+
+	var finished bool
+	for _, v := range schedule.Payload.Date.Games {
+		id := v.Profile.GameID
+		away := chineseName(b.teamMap, v.AwayTeam.FullName())
+		home := chineseName(b.teamMap, v.HomeTeam.FullName())
+
+		var leader string
+		switch {
+		case v.Boxscore.AwayScore > v.Boxscore.HomeScore:
+			leader = away
+		case v.Boxscore.HomeScore > v.Boxscore.AwayScore:
+			leader = home
+		}
+
+		prevStatus, seen := b.status[id]
+		if seen && v.Boxscore.Status == StatusLive && leader != "" && b.leader[id] != "" && leader != b.leader[id] {
+			b.changes[id]++
+			b.events = append(b.events, fmt.Sprintf("%s %s 反超 %d:%d (%s @ %s)", now, leader, v.Boxscore.AwayScore, v.Boxscore.HomeScore, away, home))
+		}
+		if leader != "" {
+			b.leader[id] = leader
+		}
+
+		if seen && prevStatus != StatusFinal && v.Final() {
+			finished = true
+			b.events = append(b.events, fmt.Sprintf("%s 比賽結束 %s %d : %d %s", now, away, v.Boxscore.AwayScore, v.Boxscore.HomeScore, home))
+		}
+		b.status[id] = v.Boxscore.Status
+	}
+
+	//只留最近的事件
+	if len(b.events) > 10 {
+		b.events = b.events[len(b.events)-10:]
+	}
+
+	if finished {
+		saveToStore(func(s *Store) error {
+			return s.SaveSchedule(date, schedule)
+		})
+	}
+}
+
+func (b *scoreboard) allFinal(schedule Schedule) bool {
+	for _, v := range schedule.Payload.Date.Games {
+		if !v.Final() {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *scoreboard) render(date string, schedule Schedule) (msg string) {
+	msg = date + " 比分，更新時間 " + time.Now().In(taipei).Format("15:04:05") + "\n\n"
+
+	for i, v := range schedule.Payload.Date.Games {
+		away := chineseName(b.teamMap, v.AwayTeam.FullName())
+		home := chineseName(b.teamMap, v.HomeTeam.FullName())
+
+		msg = msg + fmt.Sprintf("%d. %s @ %s  %s", i+1, away, home, gameStatus(v))
+		if changes := leadChanges(v, b.changes[v.Profile.GameID]); changes > 0 {
+			msg = msg + fmt.Sprintf("  領先變換 %d 次", changes)
+		}
+		msg = msg + "\n"
+
+		msg = msg + "         Q1   Q2   Q3   Q4   OT  總分\n"
+		msg = msg + quarterLine(away, v.AwayTeam, v.Boxscore.Status) + "\n"
+		msg = msg + quarterLine(home, v.HomeTeam, v.Boxscore.Status) + "\n\n"
+	}
+
+	if len(b.events) > 0 {
+		msg = msg + "---------------------------------\n"
+		for _, e := range b.events {
+			msg = msg + e + "\n"
+		}
+	}
+	return msg
+}
+
+//比賽狀態，eg: "未開賽 08:30"、"LIVE 第3節 05:21"、"FINAL"
+func gameStatus(v Game) string {
+	switch v.Boxscore.Status {
+	case StatusLive:
+		period := "第" + v.Boxscore.Period + "節"
+		if p, _ := strconv.Atoi(v.Boxscore.Period); p > 4 {
+			period = "OT" + strconv.Itoa(p-4)
+		}
+		if v.Boxscore.PeriodClock != nil {
+			period = period + " " + fmt.Sprint(v.Boxscore.PeriodClock)
+		}
+		return "LIVE " + period
+	case StatusFinal:
+		if p, _ := strconv.Atoi(v.Boxscore.Period); p > 4 {
+			return "FINAL/OT"
+		}
+		return "FINAL"
+	default:
+		t, _ := time.Parse("2006-01-02T15:04", v.Profile.DateTimeEt)
+		return "未開賽 " + t.Add(time.Hour*13).Format("15:04")
+	}
+}
+
+//nba.com 有給領先變換就用它的，不然用自己看到的次數
+func leadChanges(v Game, seen int) int {
+	if v.Boxscore.LeadChanges != nil {
+		if n, err := strconv.Atoi(fmt.Sprint(v.Boxscore.LeadChanges)); err == nil {
+			return n
+		}
+	}
+	return seen
+}
+
+//延長賽的總分
+func overtimeScore(t GameTeam) int {
+	s := t.Score
+	return s.Ot1Score + s.Ot2Score + s.Ot3Score + s.Ot4Score + s.Ot5Score +
+		s.Ot6Score + s.Ot7Score + s.Ot8Score + s.Ot9Score + s.Ot10Score
+}
+
+func quarterLine(name string, t GameTeam, status string) string {
+	if status != StatusLive && status != StatusFinal {
+		return fmt.Sprintf("  %-5s %4s %4s %4s %4s %4s %5s", name, "-", "-", "-", "-", "-", "-")
+	}
+
+	ot := "-"
+	if n := overtimeScore(t); n > 0 {
+		ot = strconv.Itoa(n)
+	}
+	return fmt.Sprintf("  %-5s %4d %4d %4d %4d %4s %5d", name, t.Score.Q1Score, t.Score.Q2Score, t.Score.Q3Score, t.Score.Q4Score, ot, t.Score.Score)
+}
@@ -1,5 +1,56 @@
 package main
 
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
 func main() {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		reportCmd(os.Args[1:])
+		return
+	}
+
+	switch os.Args[1] {
+	case "injuries":
+		injuriesCmd(os.Args[2:])
+	case "watch":
+		watchCmd(os.Args[2:])
+	case "backfill":
+		backfillCmd(os.Args[2:])
+	case "serve":
+		serveCmd(os.Args[2:])
+	case "live":
+		liveCmd(os.Args[2:])
+	case "spread":
+		spreadCmd(os.Args[2:])
+	case "standings":
+		standingsCmd(os.Args[2:])
+	case "impact":
+		impactCmd(os.Args[2:])
+	case "backtest":
+		backtestCmd(os.Args[2:])
+	case "bet":
+		betCmd(os.Args[2:])
+	case "players":
+		playersCmd(os.Args[2:])
+	default:
+		fmt.Println("未知的指令: " + os.Args[1])
+		os.Exit(2)
+	}
+}
+
+//scanNBA [-n 5] [-skip-if-necessary] [-season 2022-23]
+//
+//沒有指令時印出今天的比賽，-season 是別季時只印出，不寫入資料庫
+func reportCmd(args []string) {
+	fs := flag.NewFlagSet("scanNBA", flag.ExitOnError)
+	fs.IntVar(&atsLookback, "n", atsLookback, "讓分和大小分看最近幾場")
+	fs.BoolVar(&skipIfNecessary, "skip-if-necessary", skipIfNecessary, "略過季後賽「如果需要」才打的比賽")
+	seasonFlag(fs)
+	fs.Parse(args)
+
 	PKTeam()
 }
@@ -6,12 +6,9 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
 type Schedule struct {
@@ -52,190 +49,7 @@ type Schedule struct {
 			YearDisplay             string `json:"yearDisplay"`
 		} `json:"season"`
 		Date struct {
-			Games []struct {
-				Profile struct {
-					ArenaLocation string      `json:"arenaLocation"`
-					ArenaName     string      `json:"arenaName"`
-					AwayTeamID    string      `json:"awayTeamId"`
-					DateTimeEt    string      `json:"dateTimeEt"`
-					GameID        string      `json:"gameId"`
-					HomeTeamID    string      `json:"homeTeamId"`
-					Number        string      `json:"number"`
-					ScheduleCode  interface{} `json:"scheduleCode"`
-					SeasonType    string      `json:"seasonType"`
-					Sequence      string      `json:"sequence"`
-					UtcMillis     string      `json:"utcMillis"`
-				} `json:"profile"`
-				Boxscore struct {
-					Attendance            string      `json:"attendance"`
-					AwayScore             int         `json:"awayScore"`
-					GameLength            interface{} `json:"gameLength"`
-					HomeScore             int         `json:"homeScore"`
-					LeadChanges           interface{} `json:"leadChanges"`
-					OfficialsDisplayName1 interface{} `json:"officialsDisplayName1"`
-					OfficialsDisplayName2 interface{} `json:"officialsDisplayName2"`
-					OfficialsDisplayName3 interface{} `json:"officialsDisplayName3"`
-					Period                string      `json:"period"`
-					PeriodClock           interface{} `json:"periodClock"`
-					Status                string      `json:"status"`
-					StatusDesc            interface{} `json:"statusDesc"`
-					Ties                  interface{} `json:"ties"`
-				} `json:"boxscore"`
-				Urls         []interface{} `json:"urls"`
-				Broadcasters []interface{} `json:"broadcasters"`
-				HomeTeam     struct {
-					Profile struct {
-						Abbr              string `json:"abbr"`
-						City              string `json:"city"`
-						CityEn            string `json:"cityEn"`
-						Code              string `json:"code"`
-						Conference        string `json:"conference"`
-						DisplayAbbr       string `json:"displayAbbr"`
-						DisplayConference string `json:"displayConference"`
-						Division          string `json:"division"`
-						ID                string `json:"id"`
-						IsAllStarTeam     bool   `json:"isAllStarTeam"`
-						IsLeagueTeam      bool   `json:"isLeagueTeam"`
-						LeagueID          string `json:"leagueId"`
-						Name              string `json:"name"`
-						NameEn            string `json:"nameEn"`
-					} `json:"profile"`
-					Matchup struct {
-						ConfRank   string      `json:"confRank"`
-						DivRank    string      `json:"divRank"`
-						Losses     string      `json:"losses"`
-						SeriesText interface{} `json:"seriesText"`
-						Wins       string      `json:"wins"`
-					} `json:"matchup"`
-					Score struct {
-						Assists                int     `json:"assists"`
-						BiggestLead            int     `json:"biggestLead"`
-						Blocks                 int     `json:"blocks"`
-						BlocksAgainst          int     `json:"blocksAgainst"`
-						DefRebs                int     `json:"defRebs"`
-						Disqualifications      int     `json:"disqualifications"`
-						Ejections              int     `json:"ejections"`
-						FastBreakPoints        int     `json:"fastBreakPoints"`
-						Fga                    int     `json:"fga"`
-						Fgm                    int     `json:"fgm"`
-						Fgpct                  float64 `json:"fgpct"`
-						FlagrantFouls          int     `json:"flagrantFouls"`
-						Fouls                  int     `json:"fouls"`
-						Fta                    int     `json:"fta"`
-						Ftm                    int     `json:"ftm"`
-						Ftpct                  float64 `json:"ftpct"`
-						FullTimeoutsRemaining  int     `json:"fullTimeoutsRemaining"`
-						Mins                   int     `json:"mins"`
-						OffRebs                int     `json:"offRebs"`
-						Ot10Score              int     `json:"ot10Score"`
-						Ot1Score               int     `json:"ot1Score"`
-						Ot2Score               int     `json:"ot2Score"`
-						Ot3Score               int     `json:"ot3Score"`
-						Ot4Score               int     `json:"ot4Score"`
-						Ot5Score               int     `json:"ot5Score"`
-						Ot6Score               int     `json:"ot6Score"`
-						Ot7Score               int     `json:"ot7Score"`
-						Ot8Score               int     `json:"ot8Score"`
-						Ot9Score               int     `json:"ot9Score"`
-						PointsInPaint          int     `json:"pointsInPaint"`
-						PointsOffTurnovers     int     `json:"pointsOffTurnovers"`
-						Q1Score                int     `json:"q1Score"`
-						Q2Score                int     `json:"q2Score"`
-						Q3Score                int     `json:"q3Score"`
-						Q4Score                int     `json:"q4Score"`
-						Rebs                   int     `json:"rebs"`
-						Score                  int     `json:"score"`
-						Seconds                int     `json:"seconds"`
-						ShortTimeoutsRemaining int     `json:"shortTimeoutsRemaining"`
-						Steals                 int     `json:"steals"`
-						TechnicalFouls         int     `json:"technicalFouls"`
-						Tpa                    int     `json:"tpa"`
-						Tpm                    int     `json:"tpm"`
-						Tppct                  float64 `json:"tppct"`
-						Turnovers              int     `json:"turnovers"`
-					} `json:"score"`
-					PointGameLeader   interface{} `json:"pointGameLeader"`
-					AssistGameLeader  interface{} `json:"assistGameLeader"`
-					ReboundGameLeader interface{} `json:"reboundGameLeader"`
-				} `json:"homeTeam"`
-				AwayTeam struct {
-					Profile struct {
-						Abbr              string `json:"abbr"`
-						City              string `json:"city"`
-						CityEn            string `json:"cityEn"`
-						Code              string `json:"code"`
-						Conference        string `json:"conference"`
-						DisplayAbbr       string `json:"displayAbbr"`
-						DisplayConference string `json:"displayConference"`
-						Division          string `json:"division"`
-						ID                string `json:"id"`
-						IsAllStarTeam     bool   `json:"isAllStarTeam"`
-						IsLeagueTeam      bool   `json:"isLeagueTeam"`
-						LeagueID          string `json:"leagueId"`
-						Name              string `json:"name"`
-						NameEn            string `json:"nameEn"`
-					} `json:"profile"`
-					Matchup struct {
-						ConfRank   string      `json:"confRank"`
-						DivRank    string      `json:"divRank"`
-						Losses     string      `json:"losses"`
-						SeriesText interface{} `json:"seriesText"`
-						Wins       string      `json:"wins"`
-					} `json:"matchup"`
-					Score struct {
-						Assists                int     `json:"assists"`
-						BiggestLead            int     `json:"biggestLead"`
-						Blocks                 int     `json:"blocks"`
-						BlocksAgainst          int     `json:"blocksAgainst"`
-						DefRebs                int     `json:"defRebs"`
-						Disqualifications      int     `json:"disqualifications"`
-						Ejections              int     `json:"ejections"`
-						FastBreakPoints        int     `json:"fastBreakPoints"`
-						Fga                    int     `json:"fga"`
-						Fgm                    int     `json:"fgm"`
-						Fgpct                  float64 `json:"fgpct"`
-						FlagrantFouls          int     `json:"flagrantFouls"`
-						Fouls                  int     `json:"fouls"`
-						Fta                    int     `json:"fta"`
-						Ftm                    int     `json:"ftm"`
-						Ftpct                  float64 `json:"ftpct"`
-						FullTimeoutsRemaining  int     `json:"fullTimeoutsRemaining"`
-						Mins                   int     `json:"mins"`
-						OffRebs                int     `json:"offRebs"`
-						Ot10Score              int     `json:"ot10Score"`
-						Ot1Score               int     `json:"ot1Score"`
-						Ot2Score               int     `json:"ot2Score"`
-						Ot3Score               int     `json:"ot3Score"`
-						Ot4Score               int     `json:"ot4Score"`
-						Ot5Score               int     `json:"ot5Score"`
-						Ot6Score               int     `json:"ot6Score"`
-						Ot7Score               int     `json:"ot7Score"`
-						Ot8Score               int     `json:"ot8Score"`
-						Ot9Score               int     `json:"ot9Score"`
-						PointsInPaint          int     `json:"pointsInPaint"`
-						PointsOffTurnovers     int     `json:"pointsOffTurnovers"`
-						Q1Score                int     `json:"q1Score"`
-						Q2Score                int     `json:"q2Score"`
-						Q3Score                int     `json:"q3Score"`
-						Q4Score                int     `json:"q4Score"`
-						Rebs                   int     `json:"rebs"`
-						Score                  int     `json:"score"`
-						Seconds                int     `json:"seconds"`
-						ShortTimeoutsRemaining int     `json:"shortTimeoutsRemaining"`
-						Steals                 int     `json:"steals"`
-						TechnicalFouls         int     `json:"technicalFouls"`
-						Tpa                    int     `json:"tpa"`
-						Tpm                    int     `json:"tpm"`
-						Tppct                  float64 `json:"tppct"`
-						Turnovers              int     `json:"turnovers"`
-					} `json:"score"`
-					PointGameLeader   interface{} `json:"pointGameLeader"`
-					AssistGameLeader  interface{} `json:"assistGameLeader"`
-					ReboundGameLeader interface{} `json:"reboundGameLeader"`
-				} `json:"awayTeam"`
-				IfNecessary bool        `json:"ifNecessary"`
-				SeriesText  interface{} `json:"seriesText"`
-			} `json:"games"`
+			Games      []Game `json:"games"`
 			DateMillis string `json:"dateMillis"`
 			GameCount  string `json:"gameCount"`
 		} `json:"date"`
@@ -245,6 +59,166 @@ type Schedule struct {
 	Timestamp string `json:"timestamp"`
 }
 
+//單場比賽
+type Game struct {
+	Profile struct {
+		ArenaLocation string      `json:"arenaLocation"`
+		ArenaName     string      `json:"arenaName"`
+		AwayTeamID    string      `json:"awayTeamId"`
+		DateTimeEt    string      `json:"dateTimeEt"`
+		GameID        string      `json:"gameId"`
+		HomeTeamID    string      `json:"homeTeamId"`
+		Number        string      `json:"number"`
+		ScheduleCode  interface{} `json:"scheduleCode"`
+		SeasonType    string      `json:"seasonType"`
+		Sequence      string      `json:"sequence"`
+		UtcMillis     string      `json:"utcMillis"`
+	} `json:"profile"`
+	Boxscore struct {
+		Attendance            string      `json:"attendance"`
+		AwayScore             int         `json:"awayScore"`
+		GameLength            interface{} `json:"gameLength"`
+		HomeScore             int         `json:"homeScore"`
+		LeadChanges           interface{} `json:"leadChanges"`
+		OfficialsDisplayName1 interface{} `json:"officialsDisplayName1"`
+		OfficialsDisplayName2 interface{} `json:"officialsDisplayName2"`
+		OfficialsDisplayName3 interface{} `json:"officialsDisplayName3"`
+		Period                string      `json:"period"`
+		PeriodClock           interface{} `json:"periodClock"`
+		Status                string      `json:"status"`
+		StatusDesc            interface{} `json:"statusDesc"`
+		Ties                  interface{} `json:"ties"`
+	} `json:"boxscore"`
+	Urls         []interface{} `json:"urls"`
+	Broadcasters []interface{} `json:"broadcasters"`
+	HomeTeam     GameTeam      `json:"homeTeam"`
+	AwayTeam     GameTeam      `json:"awayTeam"`
+	IfNecessary  bool          `json:"ifNecessary"`
+	SeriesText   interface{}   `json:"seriesText"`
+}
+
+//比賽中的一隊
+type GameTeam struct {
+	Profile struct {
+		Abbr              string `json:"abbr"`
+		City              string `json:"city"`
+		CityEn            string `json:"cityEn"`
+		Code              string `json:"code"`
+		Conference        string `json:"conference"`
+		DisplayAbbr       string `json:"displayAbbr"`
+		DisplayConference string `json:"displayConference"`
+		Division          string `json:"division"`
+		ID                string `json:"id"`
+		IsAllStarTeam     bool   `json:"isAllStarTeam"`
+		IsLeagueTeam      bool   `json:"isLeagueTeam"`
+		LeagueID          string `json:"leagueId"`
+		Name              string `json:"name"`
+		NameEn            string `json:"nameEn"`
+	} `json:"profile"`
+	Matchup struct {
+		ConfRank   string      `json:"confRank"`
+		DivRank    string      `json:"divRank"`
+		Losses     string      `json:"losses"`
+		SeriesText interface{} `json:"seriesText"`
+		Wins       string      `json:"wins"`
+	} `json:"matchup"`
+	Score struct {
+		Assists                int     `json:"assists"`
+		BiggestLead            int     `json:"biggestLead"`
+		Blocks                 int     `json:"blocks"`
+		BlocksAgainst          int     `json:"blocksAgainst"`
+		DefRebs                int     `json:"defRebs"`
+		Disqualifications      int     `json:"disqualifications"`
+		Ejections              int     `json:"ejections"`
+		FastBreakPoints        int     `json:"fastBreakPoints"`
+		Fga                    int     `json:"fga"`
+		Fgm                    int     `json:"fgm"`
+		Fgpct                  float64 `json:"fgpct"`
+		FlagrantFouls          int     `json:"flagrantFouls"`
+		Fouls                  int     `json:"fouls"`
+		Fta                    int     `json:"fta"`
+		Ftm                    int     `json:"ftm"`
+		Ftpct                  float64 `json:"ftpct"`
+		FullTimeoutsRemaining  int     `json:"fullTimeoutsRemaining"`
+		Mins                   int     `json:"mins"`
+		OffRebs                int     `json:"offRebs"`
+		Ot10Score              int     `json:"ot10Score"`
+		Ot1Score               int     `json:"ot1Score"`
+		Ot2Score               int     `json:"ot2Score"`
+		Ot3Score               int     `json:"ot3Score"`
+		Ot4Score               int     `json:"ot4Score"`
+		Ot5Score               int     `json:"ot5Score"`
+		Ot6Score               int     `json:"ot6Score"`
+		Ot7Score               int     `json:"ot7Score"`
+		Ot8Score               int     `json:"ot8Score"`
+		Ot9Score               int     `json:"ot9Score"`
+		PointsInPaint          int     `json:"pointsInPaint"`
+		PointsOffTurnovers     int     `json:"pointsOffTurnovers"`
+		Q1Score                int     `json:"q1Score"`
+		Q2Score                int     `json:"q2Score"`
+		Q3Score                int     `json:"q3Score"`
+		Q4Score                int     `json:"q4Score"`
+		Rebs                   int     `json:"rebs"`
+		Score                  int     `json:"score"`
+		Seconds                int     `json:"seconds"`
+		ShortTimeoutsRemaining int     `json:"shortTimeoutsRemaining"`
+		Steals                 int     `json:"steals"`
+		TechnicalFouls         int     `json:"technicalFouls"`
+		Tpa                    int     `json:"tpa"`
+		Tpm                    int     `json:"tpm"`
+		Tppct                  float64 `json:"tppct"`
+		Turnovers              int     `json:"turnovers"`
+	} `json:"score"`
+	//領先球員的格式不固定，用到時才用 Leaders 解析，格式不對也不會讓整天的賽程讀不到
+	PointGameLeader   json.RawMessage `json:"pointGameLeader"`
+	AssistGameLeader  json.RawMessage `json:"assistGameLeader"`
+	ReboundGameLeader json.RawMessage `json:"reboundGameLeader"`
+}
+
+//得分、助攻、籃板的領先球員，null 或看不懂的略過
+func (t GameTeam) Leaders() (result []GameLeader) {
+	for _, raw := range []json.RawMessage{t.PointGameLeader, t.AssistGameLeader, t.ReboundGameLeader} {
+		var leader *GameLeader
+		if err := json.Unmarshal(raw, &leader); err != nil || leader == nil {
+			continue
+		}
+		result = append(result, *leader)
+	}
+	return result
+}
+
+//單場的領先球員，比賽還沒開始是 null
+type GameLeader struct {
+	Profile struct {
+		DisplayName string `json:"displayName"`
+		FirstName   string `json:"firstName"`
+		LastName    string `json:"lastName"`
+	} `json:"profile"`
+	StatTotal struct {
+		Mins    jsonFloat `json:"mins"`
+		Points  jsonFloat `json:"points"`
+		Assists jsonFloat `json:"assists"`
+		Rebs    jsonFloat `json:"rebs"`
+	} `json:"statTotal"`
+}
+
+//數字欄位有時候是字串，eg: "35"，空字串當作 0
+type jsonFloat float64
+
+func (f *jsonFloat) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
 //把隊伍存進map
 func TeamInit() map[string]string {
 
@@ -283,100 +257,98 @@ func TeamInit() map[string]string {
 	return teamMap
 }
 
+//隊伍縮寫對應全名
+func TeamAbbrInit() map[string]string {
+
+	abbrMap := make(map[string]string)
+	abbrMap["ATL"] = "Atlanta Hawks"
+	abbrMap["BOS"] = "Boston Celtics"
+	abbrMap["BKN"] = "Brooklyn Nets"
+	abbrMap["CLE"] = "Cleveland Cavaliers"
+	abbrMap["CHA"] = "Charlotte Hornets"
+	abbrMap["CHI"] = "Chicago Bulls"
+	abbrMap["DAL"] = "Dallas Mavericks"
+	abbrMap["DEN"] = "Denver Nuggets"
+	abbrMap["DET"] = "Detroit Pistons"
+	abbrMap["GSW"] = "Golden State Warriors"
+	abbrMap["HOU"] = "Houston Rockets"
+	abbrMap["IND"] = "Indiana Pacers"
+	abbrMap["LAL"] = "Los Angeles Lakers"
+	abbrMap["LAC"] = "Los Angeles Clippers"
+	abbrMap["MEM"] = "Memphis Grizzlies"
+	abbrMap["MIA"] = "Miami Heat"
+	abbrMap["MIL"] = "Milwaukee Bucks"
+	abbrMap["MIN"] = "Minnesota Timberwolves"
+	abbrMap["NOP"] = "New Orleans Pelicans"
+	abbrMap["NYK"] = "New York Knicks"
+	abbrMap["OKC"] = "Oklahoma City Thunder"
+	abbrMap["ORL"] = "Orlando Magic"
+	abbrMap["PHI"] = "Philadelphia 76ers"
+	abbrMap["PHX"] = "Phoenix Suns"
+	abbrMap["POR"] = "Portland Trail Blazers"
+	abbrMap["SAC"] = "Sacramento Kings"
+	abbrMap["SAS"] = "San Antonio Spurs"
+	abbrMap["TOR"] = "Toronto Raptors"
+	abbrMap["UTA"] = "Utah Jazz"
+	abbrMap["WAS"] = "Washington Wizards"
+
+	return abbrMap
+}
+
 //Get the injuriers of the nba team
 func getInjury(searchTeam string) (result []string) {
 
-	if searchTeam == "Los Angeles Clippers" {
-		searchTeam = "LA Clippers"
-	}
-
-	res, err := http.Get("https://www.espn.com/nba/injuries")
+	injuries, err := loadInjuries()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	doc.Find(".Table__league-injuries").Each(func(i int, s *goquery.Selection) {
-		team := s.Find(".injuries__teamName").Text()
-
-		if strings.EqualFold(team, searchTeam) {
-
-			s.Find(".Table__even").Each((func(i int, g *goquery.Selection) {
-				name := g.Find(".AnchorLink").Text()
-				status := g.Find(".col-stat").Text()
-				comment := g.Find(".col-desc").Text()
-
-				if status != "" {
-					name = fmt.Sprintf("%-25s", name)
-					status = fmt.Sprintf("%-15s", status)
-					comment = fmt.Sprintf("%-5s", comment)
-					injury := name + status + comment
-					result = append(result, injury)
-				}
-			}))
-		}
-	})
-
-	return result
+	return injuryLines(teamInjuries(injuries, searchTeam))
 
 }
 
 //取得Injury的comment
 func GetInjuryComment(searchTeam string) (result string) {
 
-	if searchTeam == "Los Angeles Clippers" {
-		searchTeam = "LA Clippers"
-	}
-
-	res, err := http.Get("https://www.espn.com/nba/injuries")
+	injuries, err := loadInjuries()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer res.Body.Close()
+	teamInjury := teamInjuries(injuries, searchTeam)
+	sortByImpact(loadImpacts(), teamInjury)
+	return injuryComment(TeamInit(), searchTeam, teamInjury)
+}
 
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+//傷兵名單的每一行，球員、狀態、說明對齊
+func injuryLines(injuries []Injury) (result []string) {
+	for _, v := range injuries {
+		name := fmt.Sprintf("%-25s", v.Player)
+		status := fmt.Sprintf("%-15s", v.Status)
+		comment := fmt.Sprintf("%-5s", v.Comment)
+		injury := name + status + comment
+		if v.Conflict != "" {
+			injury = injury + " [來源不一致 " + v.Conflict + "]"
+		}
+		result = append(result, injury)
 	}
+	return result
+}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
+//某隊傷兵的中文說明，eg: "湖人--LeBron James不上/"
+func injuryComment(teamMap map[string]string, searchTeam string, injuries []Injury) (result string) {
+	if len(injuries) == 0 {
+		return result
 	}
 
-	teamMap := TeamInit()
-
-	doc.Find(".Table__league-injuries").Each(func(i int, s *goquery.Selection) {
-		team := s.Find(".injuries__teamName").Text()
-
-		if strings.EqualFold(team, searchTeam) {
-			result = result + teamMap[searchTeam] + "--"
-			s.Find(".Table__even").Each((func(ii int, g *goquery.Selection) {
-
-				name := g.Find(".AnchorLink").Text()
-				status := g.Find(".col-stat").Text()
-				comment := g.Find(".col-desc").Text()
-
-				if status != "" {
-
-					commentResult := sortComment(name, comment)
-					result = result + commentResult
-
-				}
-
-			}))
+	result = result + chineseName(teamMap, searchTeam) + "--"
+	for _, v := range injuries {
+		commentResult := sortComment(v.Player, v.Comment)
+		if v.Conflict != "" {
+			commentResult = strings.TrimSuffix(commentResult, "/") + "(來源不一致)/"
 		}
-	})
+		result = result + commentResult
+	}
 
 	return result
 }
@@ -410,105 +382,64 @@ func sortComment(name, comment string) (result string) {
 //Get the nba game of the day
 func PKTeam() {
 	startTime := time.Now()
-	//轉成UTC-4
-	zone := time.FixedZone("", -4*60*60)
-	today := time.Now()
-	newTime := today.In(zone).Format("2006-01-02")
-
-	//get data api url
-	url := "https://in.global.nba.com/stats2/scores/daily.json?gameDate=" + newTime + "&locale=en&tz=%2B8&countryCode=TW#"
-	// fmt.Println(url)
-	resp, err := http.Get(url)
+	newTime := gameDate(time.Now())
+
+	result, err := loadSchedule(newTime)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
+	saveToStore(func(s *Store) error {
+		return s.SaveSchedule(newTime, result)
+	})
 
-	defer resp.Body.Close()
-
-	var (
-		result                           Schedule
-		HomeTeamComment, AwayTeamComment string
-	)
-
-	body, err := ioutil.ReadAll(resp.Body)
+	report, err := buildReport(newTime, result, atsLookback)
 	if err != nil {
-		panic(err)
-	}
-
-	if unmarshalErr := json.Unmarshal(body, &result); unmarshalErr != nil {
-		panic(unmarshalErr)
+		fmt.Println(err)
+		return
 	}
 
-	var msg string
-	var commentMsg string
-	var layout string = "2006-01-02T15:04"
-	teamMap := TeamInit()
-	if result.Payload.Date.GameCount == "" {
-		msg = msg + "今天 " + newTime + " 沒有比賽 \n"
-		fmt.Println(msg)
+	if len(report.Games) == 0 {
+		fmt.Println("今天 " + newTime + " 沒有比賽 \n")
 		fmt.Println("Spend Time:", time.Since(startTime))
 		return
 	}
-	msg = msg + "今天 " + newTime + " 有 " + result.Payload.Date.GameCount + " 場比賽 \n"
-
-	for i, v := range result.Payload.Date.Games {
 
-		t, _ := time.Parse(layout, v.Profile.DateTimeEt)
-
-		if v.AwayTeam.Profile.Name == "Clippers" {
-			v.AwayTeam.Profile.City = "Los Angeles"
-		}
-
-		if v.HomeTeam.Profile.Name == "Clippers" {
-			v.HomeTeam.Profile.City = "Los Angeles"
-		}
-
-		AwayTeam := v.AwayTeam.Profile.City + " " + v.AwayTeam.Profile.Name
-		HomeTeam := v.HomeTeam.Profile.City + " " + v.HomeTeam.Profile.Name
+	commentMsg, msg := consoleText(&report)
+	fmt.Println(commentMsg)
+	fmt.Println(msg)
 
-		AwayTeamInjury := getInjury(AwayTeam)
-		HomeTeamInjury := getInjury(HomeTeam)
+	//-season 是別季時過盤和預測不是今天的資料，不能存到今天的日期
+	if pastSeason() {
+		fmt.Println("-season", seasonOverride, "不是目前的球季，不寫入今天的過盤和預測")
+	} else {
+		saveReport(&report)
+	}
 
-		msg = msg + fmt.Sprint(i+1) + ". " + AwayTeam + "  " + t.Add(time.Hour*13).Format("15:04") + "  " + HomeTeam + "(主)  " + "\n"
+	//送出到有設定的 LINE / Discord
+	notifyAll(notifiersFromEnv(), &report)
 
-		msg = msg + "\n  ---------------------------------\n"
-		msg = msg + "  " + AwayTeam + " injury 名單 \n"
+	fmt.Println("Spend Time:", time.Since(startTime))
+}
 
-		if len(AwayTeamInjury) == 0 {
-			msg = msg + "  沒有傷兵\n\n"
-			commentMsg = commentMsg + teamMap[AwayTeam] + "-全陣容 /"
-		} else {
-			for _, v := range AwayTeamInjury {
-				msg = msg + "  " + v + "\n"
+//把報告的過盤和預測存到資料庫
+func saveReport(report *Report) {
+	saveToStore(func(s *Store) error {
+		for _, g := range report.Games {
+			for _, t := range []TeamReport{g.Away, g.Home} {
+				if err := s.SaveATS(ATSRecord{Date: report.Date, Team: t.Name, Results: t.Dish}); err != nil {
+					return err
+				}
 			}
-			commentMsg = commentMsg + GetInjuryComment(AwayTeam) + "; "
-		}
-
-		msg = msg + "\n  ---------------------------------\n"
-
-		msg = msg + "  " + HomeTeam + " injury 名單 \n"
-		if len(HomeTeamInjury) == 0 {
-			msg = msg + "  沒有傷兵\n\n"
-			commentMsg = commentMsg + teamMap[HomeTeam] + "-全陣容"
-		} else {
-			for _, v := range HomeTeamInjury {
-				msg = msg + "  " + v + "\n"
+			if g.Prediction.Model == "" {
+				continue
+			}
+			if err := s.SavePrediction(g.Prediction); err != nil {
+				return err
 			}
-			commentMsg = commentMsg + GetInjuryComment(HomeTeam)
-			msg = msg + "\n"
 		}
-
-		AwayTeamDish := getDish(AwayTeam)
-		HomeTeamDish := getDish(HomeTeam)
-
-		msg = msg + "  " + AwayTeam + " 近期過盤狀況: " + AwayTeamDish[AwayTeam] + "\n"
-		msg = msg + "  " + HomeTeam + " 近期過盤狀況: " + HomeTeamDish[HomeTeam] + "\n\n"
-		commentMsg = commentMsg + AwayTeamComment + "   " + HomeTeamComment + "\n\n"
-
-	}
-	fmt.Println(commentMsg)
-	fmt.Println(msg)
-	fmt.Println("Spend Time:", time.Since(startTime))
+		return nil
+	})
 }
 
 //Get the nba game of the day , search by "StartTime"
@@ -525,87 +456,29 @@ func PKTeamOnStartTime(st string) {
 	}
 
 	startTime := time.Now()
-	//轉成UTC-4
-	zone := time.FixedZone("", -4*60*60)
-	today := time.Now()
-	newTime := today.In(zone).Format("2006-01-02")
-
-	//get data api url
-	url := "https://in.global.nba.com/stats2/scores/daily.json?gameDate=" + newTime + "&locale=en&tz=%2B8&countryCode=TW#"
-	fmt.Println(url)
-	resp, err := http.Get(url)
+	newTime := gameDate(time.Now())
+
+	result, err := loadSchedule(newTime)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
-	defer resp.Body.Close()
-
-	var result Schedule
-
-	body, err := ioutil.ReadAll(resp.Body)
+	report, err := buildReport(newTime, result, atsLookback)
 	if err != nil {
-		panic(err)
-	}
-
-	if unmarshalErr := json.Unmarshal(body, &result); unmarshalErr != nil {
-		panic(unmarshalErr)
+		fmt.Println(err)
+		return
 	}
 
 	var msg string
-	var layout string = "2006-01-02T15:04"
 	var count int = 0 //計算是否有隊伍
 
-	for _, v := range result.Payload.Date.Games {
-
-		t, _ := time.Parse(layout, v.Profile.DateTimeEt)
-
-		if v.AwayTeam.Profile.Name == "Clippers" {
-			v.AwayTeam.Profile.City = "Los Angeles"
-		}
-
-		if v.HomeTeam.Profile.Name == "Clippers" {
-			v.HomeTeam.Profile.City = "Los Angeles"
-		}
-
+	for _, g := range report.Games {
 		//若st == 開打時間
-		if st == t.Add(time.Hour*13).Format("15:04") {
+		if st == g.StartTime {
+			msg = msg + gameConsoleText(count, g)
 			count++
-
-			AwayTeam := v.AwayTeam.Profile.City + " " + v.AwayTeam.Profile.Name
-			HomeTeam := v.HomeTeam.Profile.City + " " + v.HomeTeam.Profile.Name
-			AwayTeamInjury := getInjury(AwayTeam)
-			HomeTeamInjury := getInjury(HomeTeam)
-			msg = msg + fmt.Sprint(count) + ". " + AwayTeam + "  " + t.Add(time.Hour*13).Format("15:04") + "  " + HomeTeam + "(主)  " + "\n"
-
-			msg = msg + "\n  ---------------------------------\n"
-			msg = msg + "  " + AwayTeam + " injury 名單 \n"
-
-			if len(AwayTeamInjury) == 0 {
-				msg = msg + "  沒有傷兵\n\n"
-			} else {
-				for _, v := range AwayTeamInjury {
-					msg = msg + "  " + v + "\n"
-				}
-
-			}
-
-			msg = msg + "\n  ---------------------------------\n"
-
-			msg = msg + "  " + HomeTeam + " injury 名單 \n"
-			if len(HomeTeamInjury) == 0 {
-				msg = msg + "  沒有傷兵\n\n"
-			} else {
-				for _, v := range HomeTeamInjury {
-					msg = msg + "  " + v + "\n"
-				}
-				msg = msg + "\n"
-			}
-			AwayTeamDish := getDish(AwayTeam)
-			HomeTeamDish := getDish(HomeTeam)
-			msg = msg + "  " + AwayTeam + " 近期過盤狀況: " + AwayTeamDish[AwayTeam] + "\n"
-			msg = msg + "  " + HomeTeam + " 近期過盤狀況: " + HomeTeamDish[HomeTeam] + "\n\n"
 		}
-
 	}
 
 	if count == 0 {
@@ -618,94 +491,124 @@ func PKTeamOnStartTime(st string) {
 	fmt.Println("Spend Time:", time.Since(startTime))
 }
 
-//取得近五場輸贏盤口資訊
-func getDish(searchTeam string) map[string]string {
-	var (
-		season string
-	)
-
-	year := time.Now()
-	month := time.Now().Format("01")
+//titan007 的球季，eg: "22-23"，季後賽可能打到七月，一到八月算上一季，九月開始算新的一季
+func dishSeason(now time.Time) (season string) {
+	month := now.Format("01")
 	monthR, _ := strconv.ParseInt(month, 10, 64)
-	if monthR < 7 {
-		season = year.AddDate(-1, 0, 0).Format("06") + "-" + year.Format("06")
+	if monthR < 9 {
+		season = now.AddDate(-1, 0, 0).Format("06") + "-" + now.Format("06")
 	} else {
-		season = year.Format("06") + "-" + year.AddDate(1, 0, 0).Format("06")
+		season = now.Format("06") + "-" + now.AddDate(1, 0, 0).Format("06")
 	}
-	yearR := year.Format("2006010215")
+	return season
+}
+
+//下載 titan007 的 l1.js
+func fetchDishData(season string) ([]byte, error) {
+	yearR := time.Now().Format("2006010215")
 
 	// http://nba.titan007.com/cn/LetGoal.aspx?SclassID=1&matchSeason=2022-2023
 	url := "https://nba.titan007.com/jsData/letGoal/" + season + "/l1.js?version=" + yearR
 
 	res, err := http.Get(url)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
 	}
-	sitemap, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var Team string
-
-	r1, _ := regexp.Compile(";")
-	r2 := r1.FindAllStringSubmatchIndex(string(sitemap), -1)
-
-	Team = (string([]byte(sitemap[r2[0][0]+17 : r2[1][0]-1])))
-	frontside, _ := regexp.Compile(`\[(.*?)]`)
-
-	frontsideTeam := frontside.FindAllStringSubmatchIndex(string(Team), -1)
-
-	matchMap := make(map[int64]string)
-	result := make(map[string]string)
+	return ioutil.ReadAll(res.Body)
+}
 
-	for i := range frontsideTeam {
-		//把隊伍資料放進map，稍後用在拿勝率
-		TeamData := (Team[frontsideTeam[i][0]:frontsideTeam[i][1]])
-		match, _ := regexp.Compile(",")
-		matchR := match.FindAllStringSubmatchIndex(TeamData, -1)
-		TeamNumber, _ := strconv.ParseInt(TeamData[1:matchR[0][0]], 10, 64) //TeamData[1:TeamData[matchR[0][0]-1]]
+//l1.js 資料列的欄位，[排名, 隊伍id, ...統計..., 近期過盤]，近期過盤最新的在前面
+const (
+	dishFieldTeam   = 1
+	dishFieldRecent = 12
+)
 
-		if searchTeam == TeamData[matchR[2][1]+1:matchR[3][0]-1] {
-			matchMap[TeamNumber] = TeamData[matchR[2][1]+1 : matchR[3][0]-1]
+//解析 l1.js 取得某隊最近 n 場的輸贏盤，最新的在前面
+//
+//l1.js 只有例行賽，季後賽只用季後賽的 matchResult
+func dishResults(season string, kind int, searchTeam string, n int) ([]DishResult, error) {
+	if kind == titanPlayoffs {
+		matches, err := loadPlayoffMatches(season)
+		if err != nil {
+			return nil, err
 		}
+		return dishFromATS(teamATS(matches, searchTeam, n).Games), nil
 	}
 
-	data := (string([]byte(sitemap[r2[1][0]+20 : r2[2][0]-1])))
-	frontsidedata := frontside.FindAllStringSubmatchIndex(string(data), -1)
-
-	//拿取勝率等資料
-	for i := range frontsidedata {
-		winPercentData := (data[frontsidedata[i][0]:frontsidedata[i][1]])
-		match, _ := regexp.Compile(",")
-		matchR := match.FindAllStringSubmatchIndex(winPercentData, -1)
-		TeamNumber, _ := strconv.ParseInt(winPercentData[matchR[0][0]+1:matchR[1][0]], 10, 64)
+	sitemap, err := loadDishData(season)
+	if err != nil {
+		return nil, err
+	}
+	teams, err := titanTeams(season)
+	if err != nil {
+		return nil, err
+	}
 
-		//比對Map內是否有這個TeamNumber，有的話加入result map內回傳
-		if _, ok := matchMap[TeamNumber]; ok {
+	//arrTeam 的下一段是每隊的資料
+	var data string
+	stmts := strings.Split(string(sitemap), ";")
+	for i, stmt := range stmts {
+		if strings.Contains(stmt, "arrTeam") && i+1 < len(stmts) {
+			data = stmts[i+1]
+			break
+		}
+	}
 
-			one := changeWinLose(winPercentData[matchR[11][0]+1 : matchR[12][1]-1])
-			two := changeWinLose(winPercentData[matchR[12][0]+1 : matchR[13][1]-1])
-			three := changeWinLose(winPercentData[matchR[13][0]+1 : matchR[14][1]-1])
-			four := changeWinLose(winPercentData[matchR[14][0]+1 : matchR[15][1]-1])
-			five := changeWinLose(winPercentData[matchR[15][0]+1 : matchR[15][1]+1])
+	var (
+		team   string //l1.js 對應到的 titan007 隊名
+		result []DishResult
+	)
+	for _, row := range jsArrays(data) {
+		if len(row) <= dishFieldRecent {
+			continue
+		}
+		id, err := strconv.Atoi(row[dishFieldTeam])
+		if err != nil || !sameTeam(teams[id], searchTeam) {
+			continue
+		}
 
-			result[matchMap[TeamNumber]] = one + "," + two + "," + three + "," + four + "," + five
+		team = teams[id]
+		for _, v := range row[dishFieldRecent:] {
+			if v == "" || len(result) >= n {
+				break
+			}
+			result = append(result, DishResult{Result: changeWinLose(v)})
 		}
+		break
+	}
+	//l1.js 沒有這隊就沒有可以替換的結果
+	if team == "" {
+		return result, nil
 	}
 
-	return result
+	//l1.js 只有輸贏，讀得到 matchResult 就改用每一場的讓分資料，盤口和淨勝分都是同一場的
+	matches, err := loadSeasonMatches(season)
+	if err != nil {
+		fmt.Println("titan007 比賽資料錯誤:", err)
+		return result, nil
+	}
+	if games := teamATS(matches, team, n).Games; len(games) > 0 {
+		result = dishFromATS(games)
+	}
 
+	return result, nil
 }
 
+//l1.js 的結果: "0" 贏盤、"1" 走盤、"2" 輸盤，看不懂的是 ATSUnknown，不算進統計
 func changeWinLose(r string) (result string) {
-	if r == "0" {
-		result = "贏"
-	} else {
-		result = "輸"
+	switch strings.Trim(strings.TrimSpace(r), "'\"") {
+	case "0":
+		result = ATSCover
+	case "1":
+		result = ATSPush
+	case "2":
+		result = ATSFail
+	default:
+		result = ATSUnknown
 	}
 	return
 }
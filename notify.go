@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//通知的目標，LINE、Discord 等都實作這個介面
+type Notifier interface {
+	Name() string
+	Notify(r *Report) error
+}
+
+var notifyClient = &http.Client{Timeout: 15 * time.Second}
+
+//從環境變數讀取要通知的目標，多個值用逗號分開
+//
+//	LINE_CHANNEL_TOKEN     LINE Messaging API 的 channel access token
+//	LINE_TO                Messaging API 要推送的 user / group id
+//	DISCORD_WEBHOOK_URLS   Discord webhook 網址
+//
+//通用 webhook 的設定見 webhooksFromEnv；LINE Notify 在 2025-03-31 停止服務，LINE_NOTIFY_TOKENS 已經不支援
+func notifiersFromEnv() (result []Notifier) {
+	if len(splitEnv("LINE_NOTIFY_TOKENS")) > 0 {
+		fmt.Println("LINE Notify 已經停止服務，請改用 LINE_CHANNEL_TOKEN 和 LINE_TO")
+	}
+
+	if channelToken := os.Getenv("LINE_CHANNEL_TOKEN"); channelToken != "" {
+		for _, to := range splitEnv("LINE_TO") {
+			result = append(result, &lineMessaging{channelToken: channelToken, to: to})
+		}
+	}
+
+	for _, webhook := range splitEnv("DISCORD_WEBHOOK_URLS") {
+		result = append(result, &discordWebhook{url: webhook})
+	}
+
+	result = append(result, webhooksFromEnv()...)
+
+	return result
+}
+
+//把報告送到每一個目標，一個失敗不影響其他
+func notifyAll(notifiers []Notifier, r *Report) {
+	for _, n := range notifiers {
+		if err := n.Notify(r); err != nil {
+			fmt.Println("通知失敗", n.Name(), ":", err)
+		}
+	}
+}
+
+func splitEnv(key string) (result []string) {
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+//報告的純文字版本，每場比賽一段
+func reportText(r *Report) []string {
+	var result []string
+	if len(r.InjuryChanges) > 0 {
+		result = append(result, injuryChangesText(r.InjuryChanges))
+		//只有傷兵變化的通知
+		if len(r.Games) == 0 {
+			return result
+		}
+	}
+
+	if len(r.Games) == 0 {
+		return []string{"今天 " + r.Date + " 沒有比賽"}
+	}
+
+	result = append(result, "今天 "+r.Date+" 有 "+fmt.Sprint(len(r.Games))+" 場比賽")
+	for i, g := range r.Games {
+		result = append(result, g.Title(i)+"\n"+g.Comment())
+	}
+	return result
+}
+
+//傷兵變化的文字，一個變化一行
+func injuryChangesText(changes []InjuryChange) string {
+	teamMap := TeamInit()
+	msg := "傷兵異動:"
+	for _, c := range changes {
+		msg = msg + "\n" + c.Text(teamMap)
+	}
+	return msg
+}
+
+//把多段文字合併，每則訊息不超過 limit 個字
+func joinText(parts []string, limit int) (result []string) {
+	var msg string
+	for _, part := range parts {
+		part = truncate(part, limit)
+		if msg != "" && len([]rune(msg))+len([]rune(part))+2 > limit {
+			result = append(result, msg)
+			msg = ""
+		}
+		if msg != "" {
+			msg = msg + "\n\n"
+		}
+		msg = msg + part
+	}
+	if msg != "" {
+		result = append(result, msg)
+	}
+	return result
+}
+
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit-1]) + "…"
+}
+
+//送出 request，status code 不是 2xx 就回傳錯誤
+func doNotify(req *http.Request) error {
+	res, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("status code error: %d %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func postJSON(url string, header map[string]string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return doNotify(req)
+}
+
+//LINE Messaging API push，一次最多 5 則、每則最多 5000 字
+type lineMessaging struct {
+	channelToken string
+	to           string
+}
+
+type lineMessage struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (n *lineMessaging) Name() string { return "line-messaging:" + n.to }
+
+func (n *lineMessaging) Notify(r *Report) error {
+	texts := joinText(reportText(r), 5000)
+	for len(texts) > 0 {
+		size := len(texts)
+		if size > 5 {
+			size = 5
+		}
+
+		var messages []lineMessage
+		for _, text := range texts[:size] {
+			messages = append(messages, lineMessage{Type: "text", Text: text})
+		}
+		texts = texts[size:]
+
+		push := struct {
+			To       string        `json:"to"`
+			Messages []lineMessage `json:"messages"`
+		}{n.to, messages}
+		if err := postJSON("https://api.line.me/v2/bot/message/push", map[string]string{"Authorization": "Bearer " + n.channelToken}, push); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Discord webhook，每場比賽一個 embed field
+//
+//一個 embed 最多 25 個 field，而且標題和所有 field 加起來不能超過 6000 字，超過就分成多則訊息
+type discordWebhook struct {
+	url string
+}
+
+const (
+	discordMaxFields     = 25
+	discordMaxEmbedChars = 6000
+)
+
+type discordEmbed struct {
+	Title  string         `json:"title,omitempty"`
+	Color  int            `json:"color,omitempty"`
+	Fields []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func (n *discordWebhook) Name() string { return "discord" }
+
+func (n *discordWebhook) Notify(r *Report) error {
+	if len(r.InjuryChanges) > 0 {
+		content := truncate(injuryChangesText(r.InjuryChanges), 2000)
+		if err := postJSON(n.url, nil, map[string]string{"content": content}); err != nil {
+			return err
+		}
+		if len(r.Games) == 0 {
+			return nil
+		}
+	}
+
+	title := "今天 " + r.Date + " 有 " + fmt.Sprint(len(r.Games)) + " 場比賽"
+	if len(r.Games) == 0 {
+		return postJSON(n.url, nil, map[string]string{"content": "今天 " + r.Date + " 沒有比賽"})
+	}
+
+	var fields []discordField
+	for i, g := range r.Games {
+		fields = append(fields, discordField{
+			Name:  truncate(g.Title(i), 256),
+			Value: truncate(g.Comment(), 1024),
+		})
+	}
+
+	for i, embed := range discordEmbeds(title, fields) {
+		if i > 0 {
+			embed.Title = title + fmt.Sprintf(" (%d)", i+1)
+		}
+		if err := postJSON(n.url, nil, map[string][]discordEmbed{"embeds": {embed}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//把 field 分到多個 embed，每個都不超過 field 數和總字數的限制，標題多留一點給編號
+func discordEmbeds(title string, fields []discordField) (result []discordEmbed) {
+	titleLen := len([]rune(title)) + 8
+	embed := discordEmbed{Color: 0xC9082A}
+	size := titleLen
+	for _, f := range fields {
+		n := len([]rune(f.Name)) + len([]rune(f.Value))
+		if len(embed.Fields) > 0 && (len(embed.Fields) >= discordMaxFields || size+n > discordMaxEmbedChars) {
+			result = append(result, embed)
+			embed = discordEmbed{Color: 0xC9082A}
+			size = titleLen
+		}
+		embed.Fields = append(embed.Fields, f)
+		size = size + n
+	}
+	if len(embed.Fields) > 0 {
+		result = append(result, embed)
+	}
+	for i := range result {
+		result[i].Title = title
+	}
+	return result
+}
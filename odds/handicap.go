@@ -0,0 +1,126 @@
+package odds
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//亞洲盤的結算結果
+const (
+	Win      = "win"
+	HalfWin  = "half-win"
+	Push     = "push"
+	HalfLoss = "half-loss"
+	Loss     = "loss"
+)
+
+//解析亞洲盤口，可能是 "5.5"、"-0.25"、"0/0.5"、"-1/1.5"，正負號寫在前面時套用到兩邊
+func ParseHandicap(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("盤口是空白")
+	}
+
+	sign := 1.0
+	switch s[0] {
+	case '-':
+		sign, s = -1, s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	parts := strings.Split(s, "/")
+	if len(parts) > 2 {
+		return 0, fmt.Errorf("盤口格式錯誤: %s", s)
+	}
+
+	var values []float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("盤口格式錯誤: %s", s)
+		}
+		values = append(values, v)
+	}
+	if len(values) == 2 && values[1]-values[0] != 0.5 {
+		return 0, fmt.Errorf("盤口兩邊要差 0.5: %s", s)
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum = sum + v
+	}
+	return sign * sum / float64(len(values)), nil
+}
+
+//0.25 結尾的盤口拆成兩半，eg: -0.25 -> [0, -0.5]，其他盤口只有一個
+func SplitHandicap(line float64) []float64 {
+	quarter := math.Abs(math.Mod(line*4, 2))
+	if quarter != 1 {
+		return []float64{line}
+	}
+	return []float64{line - 0.25, line + 0.25}
+}
+
+//盤口的文字，0.25 結尾寫成 "0/0.5" 的樣子
+func FormatHandicap(line float64) string {
+	parts := SplitHandicap(line)
+	if len(parts) == 1 {
+		return strconv.FormatFloat(line, 'f', -1, 64)
+	}
+
+	low, high := math.Abs(parts[0]), math.Abs(parts[1])
+	if low > high {
+		low, high = high, low
+	}
+	msg := strconv.FormatFloat(low, 'f', -1, 64) + "/" + strconv.FormatFloat(high, 'f', -1, 64)
+	if line < 0 {
+		msg = "-" + msg
+	}
+	return msg
+}
+
+//用這一方的盤口和贏的分數結算，大小分的大是 (-總分盤, 總分)，小是 (總分盤, -總分)
+func SettleHandicap(line, margin float64) string {
+	var score float64
+	for _, v := range SplitHandicap(line) {
+		switch diff := margin + v; {
+		case diff > 0:
+			score++
+		case diff < 0:
+			score--
+		}
+	}
+
+	if len(SplitHandicap(line)) == 1 {
+		score = score * 2
+	}
+	switch score {
+	case 2:
+		return Win
+	case 1:
+		return HalfWin
+	case -1:
+		return HalfLoss
+	case -2:
+		return Loss
+	}
+	return Push
+}
+
+//結算後的盈虧，price 是歐洲盤(decimal)賠率
+func Profit(result string, stake float64, price Price) float64 {
+	switch result {
+	case Win:
+		return stake * (price.Decimal() - 1)
+	case HalfWin:
+		return stake / 2 * (price.Decimal() - 1)
+	case HalfLoss:
+		return -stake / 2
+	case Loss:
+		return -stake
+	}
+	return 0
+}
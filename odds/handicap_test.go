@@ -0,0 +1,125 @@
+package odds
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHandicap(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"5.5", 5.5, false},
+		{"-0.25", -0.25, false},
+		{"+0.25", 0.25, false},
+		{"0/0.5", 0.25, false},
+		{"-0/0.5", -0.25, false},
+		{"-0.5/1", -0.75, false},
+		{"-1/1.5", -1.25, false},
+		{" 3 ", 3, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"0/1", 0, true},
+		{"0/0.5/1", 0, true},
+		{"--1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHandicap(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHandicap(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseHandicap(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitHandicap(t *testing.T) {
+	tests := []struct {
+		line float64
+		want []float64
+	}{
+		{-0.75, []float64{-1, -0.5}},
+		{0.25, []float64{0, 0.5}},
+		{-0.25, []float64{-0.5, 0}},
+		{1.25, []float64{1, 1.5}},
+		{-0.5, []float64{-0.5}},
+		{3, []float64{3}},
+	}
+	for _, tt := range tests {
+		if got := SplitHandicap(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitHandicap(%v) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFormatHandicap(t *testing.T) {
+	tests := []struct {
+		line float64
+		want string
+	}{
+		{-0.75, "-0.5/1"},
+		{0.25, "0/0.5"},
+		{-0.25, "-0/0.5"},
+		{5.5, "5.5"},
+		{-3, "-3"},
+	}
+	for _, tt := range tests {
+		if got := FormatHandicap(tt.line); got != tt.want {
+			t.Errorf("FormatHandicap(%v) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSettleHandicap(t *testing.T) {
+	tests := []struct {
+		line, margin float64
+		want         string
+	}{
+		//讓 0.75：贏 1 分贏半，贏 2 分全贏，沒贏全輸
+		{-0.75, 2, Win},
+		{-0.75, 1, HalfWin},
+		{-0.75, 0, Loss},
+		{-0.75, -1, Loss},
+		//受讓 0.25：平手輸半，贏球全贏
+		{0.25, 1, Win},
+		{0.25, 0, HalfWin},
+		{0.25, -1, Loss},
+		//受讓 0.75：輸 1 分輸半
+		{0.75, -1, HalfLoss},
+		{0.75, 0, Win},
+		//整數盤走盤
+		{-3, 3, Push},
+		{-3, 4, Win},
+		{-3, 2, Loss},
+		{-5.5, 6, Win},
+		{-5.5, 5, Loss},
+	}
+	for _, tt := range tests {
+		if got := SettleHandicap(tt.line, tt.margin); got != tt.want {
+			t.Errorf("SettleHandicap(%v, %v) = %s, want %s", tt.line, tt.margin, got, tt.want)
+		}
+	}
+}
+
+func TestProfit(t *testing.T) {
+	price := Price(1.9)
+	tests := []struct {
+		result string
+		want   float64
+	}{
+		{Win, 90},
+		{HalfWin, 45},
+		{Push, 0},
+		{HalfLoss, -50},
+		{Loss, -100},
+	}
+	for _, tt := range tests {
+		if got := Profit(tt.result, 100, price); !near(got, tt.want) {
+			t.Errorf("Profit(%s) = %v, want %v", tt.result, got, tt.want)
+		}
+	}
+}
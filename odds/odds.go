@@ -0,0 +1,158 @@
+//odds 處理各種賠率格式、隱含機率和亞洲盤結算
+package odds
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//賠率格式
+const (
+	FormatDecimal  = "decimal"
+	FormatAmerican = "american"
+	FormatHK       = "hk"
+)
+
+//賠率，內部一律存成歐洲盤(decimal)，包含本金
+type Price float64
+
+func FromDecimal(v float64) (Price, error) {
+	if v <= 1 {
+		return 0, fmt.Errorf("歐洲盤賠率要大於 1: %v", v)
+	}
+	return Price(v), nil
+}
+
+//美式賠率，-110 表示下 110 贏 100，+150 表示下 100 贏 150
+func FromAmerican(v float64) (Price, error) {
+	switch {
+	case v >= 100:
+		return Price(1 + v/100), nil
+	case v <= -100:
+		return Price(1 + 100/-v), nil
+	}
+	return 0, fmt.Errorf("美式賠率要 <= -100 或 >= 100: %v", v)
+}
+
+//香港盤，不含本金，0.9 表示下 1 贏 0.9
+func FromHK(v float64) (Price, error) {
+	if v <= 0 {
+		return 0, fmt.Errorf("香港盤賠率要大於 0: %v", v)
+	}
+	return Price(1 + v), nil
+}
+
+//依格式解析賠率字串，format 空白時用內容判斷：有 +/- 或絕對值 >= 100 是美式，小於 1 是香港盤，其他是歐洲盤
+func Parse(s, format string) (Price, error) {
+	s = strings.TrimSpace(s)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("賠率格式錯誤: %s", s)
+	}
+
+	if format == "" {
+		switch {
+		case strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") || math.Abs(v) >= 100:
+			format = FormatAmerican
+		case v < 1:
+			format = FormatHK
+		default:
+			format = FormatDecimal
+		}
+	}
+
+	switch format {
+	case FormatDecimal:
+		return FromDecimal(v)
+	case FormatAmerican:
+		return FromAmerican(v)
+	case FormatHK:
+		return FromHK(v)
+	}
+	return 0, fmt.Errorf("未知的賠率格式: %s", format)
+}
+
+func (p Price) Decimal() float64 {
+	return float64(p)
+}
+
+func (p Price) HK() float64 {
+	return float64(p) - 1
+}
+
+func (p Price) American() float64 {
+	if p >= 2 {
+		return (float64(p) - 1) * 100
+	}
+	return -100 / (float64(p) - 1)
+}
+
+//賠率隱含的機率，包含莊家抽水；不合理的賠率(<= 1)回傳 0
+func (p Price) Implied() float64 {
+	if p <= 1 {
+		return 0
+	}
+	return 1 / float64(p)
+}
+
+//依格式輸出，eg: "1.91"、"-110"、"0.91"
+func (p Price) Format(format string) string {
+	switch format {
+	case FormatAmerican:
+		return fmt.Sprintf("%+.0f", p.American())
+	case FormatHK:
+		return fmt.Sprintf("%.2f", p.HK())
+	}
+	return fmt.Sprintf("%.2f", p.Decimal())
+}
+
+//同一個市場所有選項的隱含機率總和，有不合理的賠率或沒有選項時回傳 0 和 false
+func impliedSum(prices []Price) (sum float64, ok bool) {
+	if len(prices) == 0 {
+		return 0, false
+	}
+	for _, p := range prices {
+		if p <= 1 {
+			return 0, false
+		}
+		sum = sum + p.Implied()
+	}
+	return sum, true
+}
+
+//同一個市場所有選項的隱含機率總和減 1，就是莊家抽水，算不出來是 0
+func Overround(prices ...Price) float64 {
+	sum, ok := impliedSum(prices)
+	if !ok {
+		return 0
+	}
+	return sum - 1
+}
+
+//去掉抽水後的機率，依隱含機率等比例縮小，有不合理的賠率時全部是 0
+func NoVig(prices ...Price) []float64 {
+	result := make([]float64, len(prices))
+	sum, ok := impliedSum(prices)
+	if !ok {
+		return result
+	}
+	for i, p := range prices {
+		result[i] = p.Implied() / sum
+	}
+	return result
+}
+
+//去掉抽水後的公平賠率，算不出來的是 0
+func FairPrices(prices ...Price) []Price {
+	result := make([]Price, 0, len(prices))
+	for _, prob := range NoVig(prices...) {
+		if prob == 0 {
+			result = append(result, 0)
+			continue
+		}
+		result = append(result, Price(1/prob))
+	}
+	return result
+}
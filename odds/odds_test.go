@@ -0,0 +1,256 @@
+package odds
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestFromAmerican(t *testing.T) {
+	tests := []struct {
+		in      float64
+		want    float64
+		wantErr bool
+	}{
+		{-110, 1 + 100.0/110, false},
+		{150, 2.5, false},
+		{100, 2, false},
+		{-100, 2, false},
+		{-200, 1.5, false},
+		{0, 0, true},
+		{50, 0, true},
+		{-99, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := FromAmerican(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("FromAmerican(%v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !near(got.Decimal(), tt.want) {
+			t.Errorf("FromAmerican(%v) = %v, want %v", tt.in, got.Decimal(), tt.want)
+		}
+	}
+}
+
+func TestFromDecimalAndHK(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    func(float64) (Price, error)
+		in      float64
+		want    float64
+		wantErr bool
+	}{
+		{"decimal", FromDecimal, 1.91, 1.91, false},
+		{"decimal", FromDecimal, 1, 0, true},
+		{"decimal", FromDecimal, -2, 0, true},
+		{"hk", FromHK, 0.91, 1.91, false},
+		{"hk", FromHK, 1.5, 2.5, false},
+		{"hk", FromHK, 0, 0, true},
+		{"hk", FromHK, -0.5, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.from(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%v) error = %v, wantErr %v", tt.name, tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !near(got.Decimal(), tt.want) {
+			t.Errorf("%s(%v) = %v, want %v", tt.name, tt.in, got.Decimal(), tt.want)
+		}
+	}
+}
+
+//每種格式轉成 Price 再轉回來要一樣
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		decimal, american, hk float64
+	}{
+		{1.5, -200, 0.5},
+		{2, 100, 1},
+		{2.5, 150, 1.5},
+		{1 + 100.0/110, -110, 100.0 / 110},
+	}
+	for _, tt := range tests {
+		p := Price(tt.decimal)
+		if !near(p.American(), tt.american) {
+			t.Errorf("Price(%v).American() = %v, want %v", tt.decimal, p.American(), tt.american)
+		}
+		if !near(p.HK(), tt.hk) {
+			t.Errorf("Price(%v).HK() = %v, want %v", tt.decimal, p.HK(), tt.hk)
+		}
+
+		a, err := FromAmerican(tt.american)
+		if err != nil || !near(a.Decimal(), tt.decimal) {
+			t.Errorf("FromAmerican(%v) = %v, %v, want %v", tt.american, a, err, tt.decimal)
+		}
+		h, err := FromHK(tt.hk)
+		if err != nil || !near(h.Decimal(), tt.decimal) {
+			t.Errorf("FromHK(%v) = %v, %v, want %v", tt.hk, h, err, tt.decimal)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, format string
+		want       float64
+		wantErr    bool
+	}{
+		{"-110", "", 1 + 100.0/110, false},
+		{"+150", "", 2.5, false},
+		{"150", "", 2.5, false},
+		{"0.91", "", 1.91, false},
+		{"1.91", "", 1.91, false},
+		{"1.91", FormatDecimal, 1.91, false},
+		{"0.9", FormatHK, 1.9, false},
+		{"-110", FormatAmerican, 1 + 100.0/110, false},
+		{"abc", "", 0, true},
+		{"", "", 0, true},
+		{"-50", "", 0, true},
+		{"1", FormatDecimal, 0, true},
+		{"1.9", "fractional", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q, %q) error = %v, wantErr %v", tt.in, tt.format, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !near(got.Decimal(), tt.want) {
+			t.Errorf("Parse(%q, %q) = %v, want %v", tt.in, tt.format, got.Decimal(), tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	p := Price(1 + 100.0/110)
+	tests := []struct {
+		format, want string
+	}{
+		{FormatDecimal, "1.91"},
+		{FormatAmerican, "-110"},
+		{FormatHK, "0.91"},
+		{"", "1.91"},
+	}
+	for _, tt := range tests {
+		if got := p.Format(tt.format); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestImplied(t *testing.T) {
+	tests := []struct {
+		in   Price
+		want float64
+	}{
+		{2, 0.5},
+		{1 + 100.0/110, 110.0 / 210},
+		{4, 0.25},
+		{1, 0},
+		{0, 0},
+		{-1.5, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Implied(); !near(got, tt.want) {
+			t.Errorf("Implied(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func american(v float64) Price {
+	p, _ := FromAmerican(v)
+	return p
+}
+
+func TestMarket(t *testing.T) {
+	tests := []struct {
+		name      string
+		prices    []Price
+		overround float64
+		noVig     []float64
+		fair      []Price
+	}{
+		{
+			name:      "-110/-110",
+			prices:    []Price{american(-110), american(-110)},
+			overround: 220.0/210 - 1,
+			noVig:     []float64{0.5, 0.5},
+			fair:      []Price{2, 2},
+		},
+		{
+			//-200 隱含 2/3，+170 隱含 100/270
+			name:      "-200/+170",
+			prices:    []Price{american(-200), american(170)},
+			overround: 2.0/3 + 100.0/270 - 1,
+			noVig:     []float64{(2.0 / 3) / (2.0/3 + 100.0/270), (100.0 / 270) / (2.0/3 + 100.0/270)},
+			fair:      []Price{Price((2.0/3 + 100.0/270) / (2.0 / 3)), Price((2.0/3 + 100.0/270) / (100.0 / 270))},
+		},
+		{
+			//三選一，隱含機率 0.5 + 0.3 + 0.25 = 1.05
+			name:      "3-way",
+			prices:    []Price{2, Price(1 / 0.3), 4},
+			overround: 0.05,
+			noVig:     []float64{0.5 / 1.05, 0.3 / 1.05, 0.25 / 1.05},
+			fair:      []Price{2.1, Price(1.05 / 0.3), 4.2},
+		},
+		{
+			name:      "no vig",
+			prices:    []Price{2, 2},
+			overround: 0,
+			noVig:     []float64{0.5, 0.5},
+			fair:      []Price{2, 2},
+		},
+		{
+			name:      "zero price",
+			prices:    []Price{2, 0},
+			overround: 0,
+			noVig:     []float64{0, 0},
+			fair:      []Price{0, 0},
+		},
+		{
+			name:      "negative price",
+			prices:    []Price{-1.9, 1.9},
+			overround: 0,
+			noVig:     []float64{0, 0},
+			fair:      []Price{0, 0},
+		},
+		{
+			name:      "empty",
+			prices:    nil,
+			overround: 0,
+			noVig:     []float64{},
+			fair:      []Price{},
+		},
+	}
+	for _, tt := range tests {
+		if got := Overround(tt.prices...); !near(got, tt.overround) {
+			t.Errorf("%s: Overround = %v, want %v", tt.name, got, tt.overround)
+		}
+
+		noVig := NoVig(tt.prices...)
+		fair := FairPrices(tt.prices...)
+		if len(noVig) != len(tt.noVig) || len(fair) != len(tt.fair) {
+			t.Errorf("%s: NoVig = %v, FairPrices = %v", tt.name, noVig, fair)
+			continue
+		}
+		var sum float64
+		for i := range noVig {
+			sum = sum + noVig[i]
+			if !near(noVig[i], tt.noVig[i]) {
+				t.Errorf("%s: NoVig[%d] = %v, want %v", tt.name, i, noVig[i], tt.noVig[i])
+			}
+			if !near(float64(fair[i]), float64(tt.fair[i])) {
+				t.Errorf("%s: FairPrices[%d] = %v, want %v", tt.name, i, fair[i], tt.fair[i])
+			}
+		}
+		//去掉抽水後的機率加起來要是 1
+		if sum != 0 && !near(sum, 1) {
+			t.Errorf("%s: NoVig sum = %v", tt.name, sum)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"example/scanNBA/pdftext"
+)
+
+//聯盟官方的傷兵報告 PDF，每場比賽列出球員、狀態(Out/Questionable/Probable...)和原因
+//
+//格式是依照公開的報告推測的，欄位是 Game Date、Game Time、Matchup、Team、Player Name、Current Status、Reason，
+//日期、比賽、隊伍只在每組的第一行出現
+type officialInjuries struct{}
+
+func (officialInjuries) Name() string {
+	return "nba"
+}
+
+//報告的網址，eg: Injury-Report_2024-10-22_05PM.pdf，後來的報告每 15 分鐘一份，eg: Injury-Report_2025-03-10_01_30PM.pdf
+func officialReportURLs(t time.Time) []string {
+	base := "https://ak-static.cms.nba.com/referee/injury/Injury-Report_" + t.Format("2006-01-02") + "_"
+	hour := t.Format("03")
+	ampm := t.Format("PM")
+
+	result := []string{base + hour + ampm + ".pdf"}
+	for minute := 45; minute >= 0; minute -= 15 {
+		if t.Minute() >= minute {
+			result = append(result, base+hour+fmt.Sprintf("_%02d", minute)+ampm+".pdf")
+		}
+	}
+	return result
+}
+
+//官方報告的 request 要有 timeout，往前找最多要試 60 個網址
+var officialClient = &http.Client{Timeout: 10 * time.Second}
+
+//報告還沒產生時 S3 回 403 或 404
+var errNoOfficialReport = fmt.Errorf("沒有這份官方傷兵報告")
+
+//從現在往前找最新的一份報告，連線失敗(不是找不到檔案)就不再往前試
+func (s officialInjuries) Fetch() ([]Injury, error) {
+	now := time.Now().In(time.FixedZone("", -4*60*60))
+	for i := 0; i < 12; i++ {
+		t := now.Add(-time.Duration(i) * time.Hour)
+		if i > 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 59, 0, 0, t.Location())
+		}
+		for _, url := range officialReportURLs(t) {
+			body, err := fetchOfficialReport(url)
+			if err == errNoOfficialReport {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			rows, err := parseOfficialReport(body)
+			if err != nil {
+				return nil, err
+			}
+			return linkOfficialInjuries(rows, loadSchedule), nil
+		}
+	}
+	return nil, fmt.Errorf("找不到最近 12 小時的官方傷兵報告")
+}
+
+func fetchOfficialReport(url string) ([]byte, error) {
+	res, err := officialClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusForbidden {
+		return nil, errNoOfficialReport
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+//報告裡的一行，Matchup 和比賽日期用來對應 GameID
+type officialRow struct {
+	Injury
+	GameDate time.Time
+	Matchup  string //eg: "LAL@GSW"
+}
+
+var officialHeaders = []string{"Game Date", "Game Time", "Matchup", "Team", "Player Name", "Current Status", "Reason"}
+
+const (
+	colDate = iota
+	colTime
+	colMatchup
+	colTeam
+	colPlayer
+	colStatus
+	colReason
+)
+
+var (
+	officialPageRegexp   = regexp.MustCompile(`^Page \d+ of \d+$`)
+	officialReportRegexp = regexp.MustCompile(`Injury Report:\s*(\d{2}/\d{2}/\d{2})\s+(\d{2}:\d{2}\s*[AP]M)`)
+)
+
+//解析官方傷兵報告，欄位位置每一頁都從表頭重新找
+func parseOfficialReport(data []byte) (result []officialRow, err error) {
+	doc, err := pdftext.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := doc.Pages()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		reportTime          time.Time
+		date, matchup, team string
+		gameDate            time.Time
+		wrapped             bool
+		zone                = time.FixedZone("", -4*60*60)
+	)
+	for _, texts := range pages {
+		var columns []float64
+		for _, line := range pdftext.Lines(texts, 2) {
+			text := pdftext.Join(line)
+			if m := officialReportRegexp.FindStringSubmatch(text); m != nil {
+				reportTime, _ = time.ParseInLocation("01/02/06 03:04PM", m[1]+" "+strings.ReplaceAll(m[2], " ", ""), zone)
+				continue
+			}
+			if officialPageRegexp.MatchString(text) {
+				continue
+			}
+			if c := officialColumns(line); c != nil {
+				columns = c
+				continue
+			}
+			if columns == nil {
+				continue
+			}
+
+			cells := officialCells(line, columns)
+			if cells[colDate] != "" {
+				date = cells[colDate]
+				gameDate, _ = time.ParseInLocation("01/02/2006", date, zone)
+			}
+			if cells[colMatchup] != "" {
+				matchup = cells[colMatchup]
+			}
+			if cells[colTeam] != "" {
+				team = cells[colTeam]
+			}
+
+			player, status, reason := cells[colPlayer], cells[colStatus], cells[colReason]
+			switch {
+			case strings.Contains(strings.ToUpper(reason), "NOT YET SUBMITTED"):
+				wrapped = false
+				continue
+			case player == "" && status == "":
+				//上一行的原因太長換行
+				if reason != "" && wrapped && len(result) > 0 {
+					result[len(result)-1].Comment = result[len(result)-1].Comment + " " + reason
+				}
+				continue
+			case player == "" || status == "":
+				wrapped = false
+				continue
+			}
+
+			row := officialRow{
+				Injury: Injury{
+					Team:    team,
+					Player:  officialName(player),
+					Status:  status,
+					Comment: reason,
+					Source:  "nba",
+				},
+				GameDate: gameDate,
+				Matchup:  strings.ReplaceAll(matchup, " ", ""),
+			}
+			result = append(result, row)
+			wrapped = true
+		}
+	}
+	if len(result) == 0 && reportTime.IsZero() {
+		return nil, fmt.Errorf("看不懂官方傷兵報告的格式")
+	}
+
+	for i := range result {
+		result[i].UpdatedAt = reportTime
+		if reportTime.IsZero() {
+			result[i].UpdatedAt = result[i].GameDate
+		}
+		if !result[i].UpdatedAt.IsZero() {
+			result[i].Date = result[i].UpdatedAt.Format("Jan 2")
+		}
+	}
+	return dropAvailable(result), nil
+}
+
+//表頭那一行每個欄位的起點，不是表頭就回傳 nil
+func officialColumns(line []pdftext.Text) []float64 {
+	columns := make([]float64, len(officialHeaders))
+	found := 0
+	for i, header := range officialHeaders {
+		for _, t := range line {
+			if strings.Contains(t.S, header) || (strings.HasPrefix(header, t.S) && len(t.S) >= 4) {
+				columns[i] = t.X
+				found++
+				break
+			}
+		}
+	}
+	if found < len(officialHeaders) {
+		return nil
+	}
+	return columns
+}
+
+//每段文字放進起點在它左邊最近的欄位，起點可以差 2pt
+func officialCells(line []pdftext.Text, columns []float64) []string {
+	parts := make([][]pdftext.Text, len(columns))
+	for _, t := range line {
+		col := 0
+		for i, x := range columns {
+			if t.X+2 >= x {
+				col = i
+			}
+		}
+		parts[col] = append(parts[col], t)
+	}
+
+	cells := make([]string, len(columns))
+	for i, p := range parts {
+		cells[i] = pdftext.Join(p)
+	}
+	return cells
+}
+
+//"James, LeBron" -> "LeBron James"，"Jackson Jr., Jaren" -> "Jaren Jackson Jr."
+func officialName(s string) string {
+	i := strings.Index(s, ",")
+	if i < 0 {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(s[i+1:]) + " " + strings.TrimSpace(s[:i])
+}
+
+//Available 是可以上場，不算傷兵
+func dropAvailable(rows []officialRow) (result []officialRow) {
+	for _, v := range rows {
+		if !strings.EqualFold(v.Status, "Available") {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+//用比賽日期的賽程把 Matchup 對應到 GameID，schedule 通常是 loadSchedule
+func linkOfficialInjuries(rows []officialRow, schedule func(date string) (Schedule, error)) (result []Injury) {
+	games := make(map[string]string) //日期|AWAY@HOME -> GameID
+	loaded := make(map[string]bool)
+
+	for _, v := range rows {
+		date := v.GameDate.Format("2006-01-02")
+		if !v.GameDate.IsZero() && !loaded[date] {
+			loaded[date] = true
+			if s, err := schedule(date); err == nil {
+				for _, g := range s.Payload.Date.Games {
+					games[date+"|"+g.AwayTeam.Profile.Abbr+"@"+g.HomeTeam.Profile.Abbr] = g.Profile.GameID
+				}
+			}
+		}
+
+		injury := v.Injury
+		injury.GameID = games[date+"|"+v.Matchup]
+		result = append(result, injury)
+	}
+	return result
+}
+
+//印出官方報告，依比賽分組
+func printOfficialReport(rows []officialRow) {
+	var current string
+	var group []Injury
+	flush := func() {
+		if len(group) == 0 {
+			return
+		}
+		fmt.Println(current)
+		for _, line := range injuryLines(group) {
+			fmt.Println("  " + line)
+		}
+		group = nil
+	}
+
+	injuries := linkOfficialInjuries(rows, loadSchedule)
+	for i, v := range rows {
+		title := v.GameDate.Format("2006-01-02") + " " + v.Matchup + " " + v.Team
+		if injuries[i].GameID != "" {
+			title = title + " (" + injuries[i].GameID + ")"
+		}
+		if title != current {
+			flush()
+			current = title
+		}
+		group = append(group, injuries[i])
+	}
+	flush()
+}
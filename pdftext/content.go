@@ -0,0 +1,216 @@
+package pdftext
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+)
+
+//頁面上的一段文字，X、Y 是左下角為原點的位置，Size 是換算後的字體大小
+type Text struct {
+	X, Y float64
+	Size float64
+	S    string
+}
+
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+//m × n
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+//抽出每一頁的文字
+func (d *Document) Pages() (result [][]Text, err error) {
+	for _, p := range d.pages() {
+		body, err := d.contents(p)
+		if err != nil {
+			return nil, err
+		}
+
+		fonts := make(map[Name]*font)
+		if dict, ok := d.Resolve(p.resources["Font"]).(Dict); ok {
+			for name, v := range dict {
+				fonts[name] = d.loadFont(v)
+			}
+		}
+		result = append(result, extract(body, fonts))
+	}
+	return result, nil
+}
+
+//執行內容 stream 內跟文字有關的運算子
+func extract(body []byte, fonts map[Name]*font) (result []Text) {
+	var (
+		ctm      = identity
+		stack    []matrix
+		tm, tlm  = identity, identity
+		leading  float64
+		cur      *font
+		fontSize = 1.0
+		moved    = true
+		operands []interface{}
+	)
+
+	number := func(i int) float64 {
+		if i < len(operands) {
+			if v, ok := operands[i].(float64); ok {
+				return v
+			}
+		}
+		return 0
+	}
+	moveTo := func(tx, ty float64) {
+		tlm = matrix{1, 0, 0, 1, tx, ty}.mul(tlm)
+		tm = tlm
+		moved = true
+	}
+	show := func(s string) {
+		if s == "" {
+			return
+		}
+		if !moved && len(result) > 0 {
+			result[len(result)-1].S = result[len(result)-1].S + s
+			return
+		}
+		m := tm.mul(ctm)
+		result = append(result, Text{X: m[4], Y: m[5], Size: fontSize * math.Hypot(m[0], m[1]), S: s})
+		moved = false
+	}
+
+	l := &lexer{data: body}
+	for !l.eof() {
+		v, err := l.object()
+		if err != nil {
+			break
+		}
+		op, isOp := v.(keyword)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			ctm = matrix{number(0), number(1), number(2), number(3), number(4), number(5)}.mul(ctm)
+			moved = true
+		case "BT":
+			tm, tlm = identity, identity
+			moved = true
+		case "Tf":
+			if len(operands) > 1 {
+				if name, ok := operands[0].(Name); ok {
+					cur = fonts[name]
+				}
+			}
+			fontSize = number(1)
+		case "TL":
+			leading = number(0)
+		case "Td":
+			moveTo(number(0), number(1))
+		case "TD":
+			leading = -number(1)
+			moveTo(number(0), number(1))
+		case "Tm":
+			tlm = matrix{number(0), number(1), number(2), number(3), number(4), number(5)}
+			tm = tlm
+			moved = true
+		case "T*":
+			moveTo(0, -leading)
+		case "Tj":
+			if s, ok := last(operands).(String); ok {
+				show(cur.decode(s))
+			}
+		case "'", "\"":
+			moveTo(0, -leading)
+			if s, ok := last(operands).(String); ok {
+				show(cur.decode(s))
+			}
+		case "TJ":
+			arr, _ := last(operands).(Array)
+			var b strings.Builder
+			for _, item := range arr {
+				switch v := item.(type) {
+				case String:
+					b.WriteString(cur.decode(v))
+				case float64:
+					//間距大於四分之一個字當作空白
+					if v < -250 && b.Len() > 0 {
+						b.WriteByte(' ')
+					}
+				}
+			}
+			show(b.String())
+		case "BI":
+			//inline image 的資料可能是二進位，直接跳到 EI
+			if i := bytes.Index(l.data[l.pos:], []byte("EI")); i >= 0 {
+				l.pos = l.pos + i + 2
+			}
+		}
+		operands = nil
+	}
+	return result
+}
+
+func last(operands []interface{}) interface{} {
+	if len(operands) == 0 {
+		return nil
+	}
+	return operands[len(operands)-1]
+}
+
+//把文字依 Y 分成一行一行，從上到下，每行內依 X 排序，Y 差距在 tol 內算同一行
+func Lines(texts []Text, tol float64) (result [][]Text) {
+	sorted := append([]Text{}, texts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Y > sorted[j].Y
+	})
+
+	for _, t := range sorted {
+		if n := len(result); n > 0 && math.Abs(result[n-1][0].Y-t.Y) <= tol {
+			result[n-1] = append(result[n-1], t)
+			continue
+		}
+		result = append(result, []Text{t})
+	}
+
+	for _, line := range result {
+		sort.SliceStable(line, func(i, j int) bool {
+			return line[i].X < line[j].X
+		})
+	}
+	return result
+}
+
+//同一欄內的文字接起來，距離超過半個字就加空白，沒有字寬只能用字數估計
+func Join(texts []Text) string {
+	var b strings.Builder
+	for i, t := range texts {
+		if i > 0 {
+			prev := texts[i-1]
+			end := prev.X + float64(len([]rune(prev.S)))*prev.Size*0.5
+			if t.X-end > prev.Size*0.25 {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(t.S)
+	}
+	return strings.TrimSpace(strings.Join(strings.Fields(b.String()), " "))
+}
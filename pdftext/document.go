@@ -0,0 +1,340 @@
+//pdftext 從 PDF 抽出每一頁的文字和位置，只處理報表這種單純的文字檔，不支援加密
+package pdftext
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+//解壓縮後一個 stream 最大的大小，避免壞掉或惡意的檔案用光記憶體
+const maxStreamSize = 64 << 20
+
+//一份 PDF，物件用到時才解析
+type Document struct {
+	data    []byte
+	offsets map[int]int //物件編號 -> "obj" 之後的位置
+	inStm   map[int]stmEntry
+	cache   map[int]interface{}
+	trailer Dict
+}
+
+//物件在 object stream 內的位置
+type stmEntry struct {
+	stream int
+	index  int
+}
+
+var objRegexp = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+//檔案內的數字當作位置或數量用，要是 0 到 max 之間的整數，不是就回傳 false
+func index(v interface{}, max int) (int, bool) {
+	f, ok := v.(float64)
+	if !ok || math.IsNaN(f) || f < 0 || f > float64(max) || f != math.Trunc(f) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+//解析 PDF，找出所有物件和 trailer
+func Open(data []byte) (*Document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte("%PDF")) {
+		return nil, fmt.Errorf("pdf: 不是 PDF 檔案")
+	}
+
+	d := &Document{
+		data:    data,
+		offsets: make(map[int]int),
+		inStm:   make(map[int]stmEntry),
+		cache:   make(map[int]interface{}),
+	}
+
+	//同一個編號出現多次時後面的(增量更新)優先
+	for _, m := range objRegexp.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		d.offsets[num] = m[1]
+	}
+	if len(d.offsets) == 0 {
+		return nil, fmt.Errorf("pdf: 找不到任何物件")
+	}
+
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		l := &lexer{data: data, pos: i + len("trailer")}
+		if v, err := l.object(); err == nil {
+			d.trailer, _ = v.(Dict)
+		}
+	}
+
+	//PDF 1.5 之後的 object stream 和 xref stream
+	for num := range d.offsets {
+		s, ok := d.object(num).(Stream)
+		if !ok {
+			continue
+		}
+		switch s.Dict["Type"] {
+		case Name("ObjStm"):
+			d.indexObjStm(num, s)
+		case Name("XRef"):
+			if d.trailer == nil {
+				d.trailer = s.Dict
+			}
+		}
+	}
+	return d, nil
+}
+
+//記下 object stream 內的物件，已經有獨立物件的不覆蓋
+func (d *Document) indexObjStm(num int, s Stream) {
+	body, err := d.Decode(s)
+	if err != nil {
+		return
+	}
+	n, ok := index(d.Resolve(s.Dict["N"]), len(body))
+	if !ok {
+		return
+	}
+	l := &lexer{data: body}
+	for i := 0; i < n; i++ {
+		v, err := l.next()
+		if err != nil {
+			return
+		}
+		obj, ok := index(v, math.MaxInt32)
+		if _, err := l.next(); err != nil || !ok {
+			return
+		}
+		if _, exists := d.offsets[obj]; !exists {
+			d.inStm[obj] = stmEntry{stream: num, index: i}
+		}
+	}
+}
+
+//取得某個編號的物件
+func (d *Document) object(num int) interface{} {
+	if v, ok := d.cache[num]; ok {
+		return v
+	}
+	d.cache[num] = nil //避免 Length 互相參照時無窮遞迴
+
+	var v interface{}
+	if offset, ok := d.offsets[num]; ok {
+		v = d.parseAt(offset)
+	} else if e, ok := d.inStm[num]; ok {
+		v = d.parseInStm(e)
+	}
+	d.cache[num] = v
+	return v
+}
+
+func (d *Document) parseAt(offset int) interface{} {
+	l := &lexer{data: d.data, pos: offset}
+	v, err := l.object()
+	if err != nil {
+		return nil
+	}
+
+	dict, ok := v.(Dict)
+	if !ok {
+		return v
+	}
+	save := l.pos
+	if k, err := l.next(); err != nil || k != keyword("stream") {
+		l.pos = save
+		return dict
+	}
+
+	//Length 不合理時用 endstream 找結尾
+	length, _ := index(d.Resolve(dict["Length"]), len(d.data))
+	return Stream{Dict: dict, Raw: l.stream(length)}
+}
+
+func (d *Document) parseInStm(e stmEntry) interface{} {
+	s, ok := d.object(e.stream).(Stream)
+	if !ok {
+		return nil
+	}
+	body, err := d.Decode(s)
+	if err != nil {
+		return nil
+	}
+
+	//N、First 和每個物件的位置都是檔案給的，超出 stream 範圍就當作找不到
+	n, ok1 := index(d.Resolve(s.Dict["N"]), len(body))
+	first, ok2 := index(d.Resolve(s.Dict["First"]), len(body))
+	if !ok1 || !ok2 || e.index >= n {
+		return nil
+	}
+	l := &lexer{data: body[:first]}
+	for i := 0; i <= e.index; i++ {
+		if _, err := l.next(); err != nil {
+			return nil
+		}
+		offset, err := l.next()
+		if err != nil {
+			return nil
+		}
+		if i == e.index {
+			pos, ok := index(offset, len(body)-first-1)
+			if !ok {
+				return nil
+			}
+			obj := &lexer{data: body, pos: first + pos}
+			v, _ := obj.object()
+			return v
+		}
+	}
+	return nil
+}
+
+//Ref 換成實際的物件，其他直接回傳
+func (d *Document) Resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		r, ok := v.(Ref)
+		if !ok {
+			return v
+		}
+		v = d.object(r.Num)
+	}
+	return nil
+}
+
+//解開 stream 的壓縮，支援 FlateDecode 和 ASCIIHexDecode
+func (d *Document) Decode(s Stream) ([]byte, error) {
+	var filters []interface{}
+	switch f := d.Resolve(s.Dict["Filter"]).(type) {
+	case Name:
+		filters = []interface{}{f}
+	case Array:
+		filters = f
+	}
+
+	body := s.Raw
+	for _, f := range filters {
+		var err error
+		switch d.Resolve(f) {
+		case Name("FlateDecode"):
+			body, err = inflate(body)
+		case Name("ASCIIHexDecode"):
+			l := &lexer{data: append(append([]byte{}, body...), '>')}
+			body = l.hex()
+		default:
+			err = fmt.Errorf("pdf: 不支援的壓縮 %v", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+//zlib 格式，標頭壞掉時改用沒有標頭的 deflate，資料結尾不完整時保留已經解開的部分
+func inflate(b []byte) ([]byte, error) {
+	if r, err := zlib.NewReader(bytes.NewReader(b)); err == nil {
+		body, err := readLimited(r)
+		if err == nil || len(body) > 0 {
+			return body, checkSize(body)
+		}
+	}
+	if len(b) > 2 {
+		body, err := readLimited(flate.NewReader(bytes.NewReader(b[2:])))
+		if err == nil || len(body) > 0 {
+			return body, checkSize(body)
+		}
+	}
+	return nil, fmt.Errorf("pdf: FlateDecode 失敗")
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	return ioutil.ReadAll(io.LimitReader(r, maxStreamSize+1))
+}
+
+func checkSize(body []byte) error {
+	if len(body) > maxStreamSize {
+		return fmt.Errorf("pdf: stream 解壓縮後超過 %d bytes", maxStreamSize)
+	}
+	return nil
+}
+
+//一頁和它繼承下來的 Resources
+type page struct {
+	dict      Dict
+	resources Dict
+}
+
+//依 Pages 樹的順序列出所有頁面
+func (d *Document) pages() (result []page) {
+	root, _ := d.Resolve(d.trailer["Root"]).(Dict)
+	if root == nil {
+		//trailer 讀不到時找 Catalog
+		for num := range d.offsets {
+			if dict, ok := d.object(num).(Dict); ok && dict["Type"] == Name("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	seen := make(map[interface{}]bool)
+	var walk func(node interface{}, resources Dict)
+	walk = func(node interface{}, resources Dict) {
+		if r, ok := node.(Ref); ok {
+			if seen[r] {
+				return
+			}
+			seen[r] = true
+		}
+		dict, ok := d.Resolve(node).(Dict)
+		if !ok {
+			return
+		}
+		if res, ok := d.Resolve(dict["Resources"]).(Dict); ok {
+			resources = res
+		}
+
+		if dict["Type"] == Name("Page") || dict["Kids"] == nil {
+			result = append(result, page{dict: dict, resources: resources})
+			return
+		}
+		kids, _ := d.Resolve(dict["Kids"]).(Array)
+		for _, kid := range kids {
+			walk(kid, resources)
+		}
+	}
+	walk(root["Pages"], nil)
+	return result
+}
+
+//某頁所有內容 stream 串起來
+func (d *Document) contents(p page) ([]byte, error) {
+	var streams []interface{}
+	switch c := d.Resolve(p.dict["Contents"]).(type) {
+	case Stream:
+		streams = []interface{}{c}
+	case Array:
+		streams = c
+	}
+
+	var buf bytes.Buffer
+	for _, v := range streams {
+		s, ok := d.Resolve(v).(Stream)
+		if !ok {
+			continue
+		}
+		body, err := d.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(body)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
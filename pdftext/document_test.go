@@ -0,0 +1,79 @@
+package pdftext
+
+import (
+	"fmt"
+	"testing"
+)
+
+//只有一個 object stream 的 PDF，stream 內是物件 1 的 Catalog
+func objStmPDF(first, offset string) []byte {
+	body := fmt.Sprintf("1 %s << /Type /Catalog >>", offset)
+	return []byte(fmt.Sprintf("%%PDF-1.5\n5 0 obj\n<< /Type /ObjStm /N 1 /First %s /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		first, len(body), body))
+}
+
+func TestParseInStm(t *testing.T) {
+	tests := []struct {
+		name          string
+		first, offset string
+		ok            bool
+	}{
+		{"valid", "4", "0", true},
+		{"negative first", "-4", "0", false},
+		{"first past end", "999", "0", false},
+		{"fractional first", "4.5", "0", false},
+		{"negative offset", "4", "-2", false},
+		{"offset past end", "4", "999", false},
+		{"first not number", "/Four", "0", false},
+	}
+	for _, tt := range tests {
+		d, err := Open(objStmPDF(tt.first, tt.offset))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		dict, _ := d.Resolve(Ref{1, 0}).(Dict)
+		if got := dict["Type"] == Name("Catalog"); got != tt.ok {
+			t.Errorf("%s: found catalog = %v, want %v", tt.name, got, tt.ok)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		max  int
+		want int
+		ok   bool
+	}{
+		{float64(3), 10, 3, true},
+		{float64(10), 10, 10, true},
+		{float64(11), 10, 0, false},
+		{float64(-1), 10, 0, false},
+		{1.5, 10, 0, false},
+		{1e30, 10, 0, false},
+		{Name("3"), 10, 0, false},
+		{nil, 10, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := index(tt.v, tt.max)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("index(%v, %d) = %d, %v, want %d, %v", tt.v, tt.max, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+//Length 超出檔案時改用 endstream 找結尾
+func TestStreamLength(t *testing.T) {
+	for _, length := range []string{"5", "-5", "99999999999999999999", "/X"} {
+		data := []byte("%PDF-1.4\n1 0 obj\n<< /Length " + length + " >>\nstream\nhello\nendstream\nendobj\n")
+		d, err := Open(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, ok := d.Resolve(Ref{1, 0}).(Stream)
+		if !ok || string(s.Raw) != "hello" {
+			t.Errorf("Length %s: raw = %q", length, s.Raw)
+		}
+	}
+}
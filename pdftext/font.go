@@ -0,0 +1,176 @@
+package pdftext
+
+import (
+	"unicode/utf16"
+)
+
+//字型的編碼，有 ToUnicode 就用 ToUnicode，沒有就當作 WinAnsi
+type font struct {
+	codeLen   int
+	toUnicode map[string]string
+}
+
+//WinAnsiEncoding 0x80~0x9F 和 Latin-1 不同的字
+var winAnsi = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+func (d *Document) loadFont(v interface{}) *font {
+	f := &font{codeLen: 1}
+	dict, ok := d.Resolve(v).(Dict)
+	if !ok {
+		return f
+	}
+	if dict["Subtype"] == Name("Type0") {
+		f.codeLen = 2
+	}
+
+	s, ok := d.Resolve(dict["ToUnicode"]).(Stream)
+	if !ok {
+		return f
+	}
+	body, err := d.Decode(s)
+	if err != nil {
+		return f
+	}
+	f.parseCMap(body)
+	return f
+}
+
+//UTF-16BE 換成字串
+func utf16String(b []byte) string {
+	var units []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+//解析 ToUnicode CMap 的 codespacerange、bfchar 和 bfrange
+func (f *font) parseCMap(body []byte) {
+	f.toUnicode = make(map[string]string)
+	l := &lexer{data: body}
+
+	var operands []interface{}
+	for !l.eof() {
+		v, err := l.object()
+		if err != nil {
+			return
+		}
+		k, isKeyword := v.(keyword)
+		if !isKeyword {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch k {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				//code 最多 4 bytes
+				if lo, ok := operands[0].(String); ok && len(lo) > 0 && len(lo) <= 4 {
+					f.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(String)
+				dst, ok2 := operands[i+1].(String)
+				if ok1 && ok2 {
+					f.toUnicode[string(src)] = utf16String(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(String)
+				hi, ok2 := operands[i+1].(String)
+				if !ok1 || !ok2 || len(lo) == 0 || len(lo) > 4 || len(lo) != len(hi) {
+					continue
+				}
+				f.addRange(lo, hi, operands[i+2])
+			}
+		}
+		if k != "" {
+			operands = nil
+		}
+	}
+}
+
+//bfrange 的目的可以是起始字串(最後一個字元依序加一)或是每個 code 的字串陣列
+func (f *font) addRange(lo, hi String, dst interface{}) {
+	start, end := codeValue(lo), codeValue(hi)
+	if end < start || end-start > 0xFFFF {
+		return
+	}
+
+	for code := start; code <= end; code++ {
+		src := codeBytes(code, len(lo))
+		switch v := dst.(type) {
+		case String:
+			if len(v) < 2 {
+				continue
+			}
+			b := append([]byte{}, v...)
+			last := uint16(b[len(b)-2])<<8 | uint16(b[len(b)-1])
+			last = last + uint16(code-start)
+			b[len(b)-2], b[len(b)-1] = byte(last>>8), byte(last)
+			f.toUnicode[string(src)] = utf16String(b)
+		case Array:
+			if i := int(code - start); i < len(v) {
+				if s, ok := v[i].(String); ok {
+					f.toUnicode[string(src)] = utf16String(s)
+				}
+			}
+		}
+	}
+}
+
+func codeValue(b []byte) (v uint32) {
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func codeBytes(v uint32, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v = v >> 8
+	}
+	return b
+}
+
+//字串依字型的編碼換成文字
+func (f *font) decode(s String) string {
+	if f == nil {
+		f = &font{codeLen: 1}
+	}
+
+	var result []rune
+	for i := 0; i < len(s); {
+		n := f.codeLen
+		if i+n > len(s) {
+			n = len(s) - i
+		}
+		code := s[i : i+n]
+		i = i + n
+
+		if f.toUnicode != nil {
+			if text, ok := f.toUnicode[string(code)]; ok {
+				result = append(result, []rune(text)...)
+				continue
+			}
+		}
+		if n == 1 {
+			if r, ok := winAnsi[code[0]]; ok {
+				result = append(result, r)
+			} else {
+				result = append(result, rune(code[0]))
+			}
+		}
+	}
+	return string(result)
+}
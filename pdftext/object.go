@@ -0,0 +1,313 @@
+package pdftext
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+//PDF 的物件，只支援抽文字需要的種類
+type (
+	Name  string
+	Dict  map[Name]interface{}
+	Array []interface{}
+	Ref   struct{ Num, Gen int }
+
+	//字串保留原始 byte，字型的編碼之後才知道
+	String []byte
+
+	Stream struct {
+		Dict Dict
+		Raw  []byte
+	}
+)
+
+//PDF 語法的 token 分析，物件和頁面內容共用
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) eof() bool {
+	l.skipSpace()
+	return l.pos >= len(l.data)
+}
+
+//一般的關鍵字或數字
+func (l *lexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+//讀一個 token，物件回傳 PDF 物件，關鍵字(obj、R、運算子)回傳 keyword
+type keyword string
+
+func (l *lexer) next() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, fmt.Errorf("pdf: 資料提早結束")
+	}
+
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		return Name(l.name()), nil
+	case c == '(':
+		l.pos++
+		return l.literal(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.dict()
+	case c == '<':
+		l.pos++
+		return l.hex(), nil
+	case c == '[':
+		l.pos++
+		return l.array()
+	case c == ']' || c == '>' || c == '{' || c == '}' || c == ')':
+		l.pos++
+		if c == '>' && l.pos < len(l.data) && l.data[l.pos] == '>' {
+			l.pos++
+			return keyword(">>"), nil
+		}
+		return keyword(string(c)), nil
+	}
+
+	w := l.word()
+	if w == "" {
+		l.pos++
+		return keyword(""), nil
+	}
+	if n, err := strconv.ParseFloat(w, 64); err == nil {
+		return n, nil
+	}
+	switch w {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return keyword(w), nil
+}
+
+//名稱內可能有 #xx
+func (l *lexer) name() string {
+	var b []byte
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+	return string(b)
+}
+
+//(...) 字串，處理跳脫字元和成對的括號
+func (l *lexer) literal() String {
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(b)
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return String(b)
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return String(b)
+}
+
+//<...> 十六進位字串，奇數個字元時最後補 0
+func (l *lexer) hex() String {
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	b := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		b = append(b, byte(v))
+	}
+	return String(b)
+}
+
+//讀一個完整的物件，數字後面接 "gen R" 時換成 Ref
+func (l *lexer) object() (interface{}, error) {
+	v, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return v, nil
+	}
+
+	save := l.pos
+	if gen, err := l.next(); err == nil {
+		if g, ok := gen.(float64); ok {
+			if r, err := l.next(); err == nil && r == keyword("R") {
+				return Ref{int(n), int(g)}, nil
+			}
+		}
+	}
+	l.pos = save
+	return n, nil
+}
+
+func (l *lexer) array() (Array, error) {
+	var result Array
+	for {
+		l.skipSpace()
+		if l.pos < len(l.data) && l.data[l.pos] == ']' {
+			l.pos++
+			return result, nil
+		}
+		v, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+}
+
+func (l *lexer) dict() (Dict, error) {
+	result := make(Dict)
+	for {
+		key, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if key == keyword(">>") {
+			return result, nil
+		}
+		name, ok := key.(Name)
+		if !ok {
+			return nil, fmt.Errorf("pdf: dict 的 key 不是 name: %v", key)
+		}
+		v, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		result[name] = v
+	}
+}
+
+//stream 關鍵字之後的原始資料，有 Length 就用 Length，沒有或不對就找 endstream
+func (l *lexer) stream(length int) []byte {
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+
+	start := l.pos
+	if length > 0 && length <= len(l.data)-start {
+		rest := l.data[start+length:]
+		if bytes.HasPrefix(bytes.TrimLeft(rest, "\r\n \t"), []byte("endstream")) {
+			l.pos = start + length
+			return l.data[start:l.pos]
+		}
+	}
+
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		l.pos = len(l.data)
+		return l.data[start:]
+	}
+	l.pos = start + end
+	return bytes.TrimRight(l.data[start:l.pos], "\r\n")
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//球員的身分，ESPN、CBS、官方報告和中文名稱都對應到同一個編號
+type Player struct {
+	ID        string    `json:"id"` //eg: "P00012"
+	Name      string    `json:"name"`
+	Team      string    `json:"team,omitempty"`    //最後一次看到的隊伍
+	Aliases   []string  `json:"aliases,omitempty"` //其他來源的寫法，包含中文名
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//所有球員和比對用的索引，在同一個 transaction 內讀寫
+type PlayerRegistry struct {
+	players map[string]*Player
+	keys    map[string][]string //比對用的名稱 -> 球員編號
+	next    int
+	changed map[string]bool
+
+	//同一份名單內已經對應到的球員和隊伍，同一份名單出現兩個別隊的同名球員時是不同人
+	claimed map[string]string
+}
+
+//常見的暱稱和正式名稱，key 和 value 都是 normalizeName 之後的寫法
+var playerAliases = map[string]string{
+	"nic claxton":    "nicolas claxton",
+	"herb jones":     "herbert jones",
+	"moe wagner":     "moritz wagner",
+	"kj martin":      "kenyon martin",
+	"bones hyland":   "nahshon hyland",
+	"og anunoby":     "ogugua anunoby",
+	"cam johnson":    "cameron johnson",
+	"cam thomas":     "cameron thomas",
+	"cam reddish":    "cameron reddish",
+	"mo bamba":       "mohamed bamba",
+	"scotty pippen":  "scottie pippen",
+	"gg jackson":     "gregory jackson",
+	"bub carrington": "carlton carrington",
+	"alex sarr":      "alexandre sarr",
+	"nene":           "nene hilario",
+}
+
+//拉丁字母的重音符號，"Dončić" -> "Doncic"
+var accentFolds = map[rune]string{}
+
+func init() {
+	groups := map[string]string{
+		"a": "áàâäãåāăą", "c": "çćĉċč", "d": "ďđ", "e": "éèêëēĕėęě", "g": "ĝğġģ", "h": "ĥħ",
+		"i": "íìîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł", "n": "ñńņňŉ", "o": "óòôöõøōŏő",
+		"r": "ŕŗř", "s": "śŝşšș", "t": "ţťŧț", "u": "úùûüũūŭůűų", "w": "ŵ", "y": "ýÿŷ", "z": "źżž",
+		"ae": "æ", "oe": "œ", "ss": "ß",
+	}
+	for base, letters := range groups {
+		for _, r := range letters {
+			accentFolds[r] = base
+			if upper := []rune(strings.ToUpper(string(r))); len(upper) == 1 && upper[0] != r {
+				accentFolds[upper[0]] = strings.ToUpper(base)
+			}
+		}
+	}
+}
+
+func foldAccents(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if v, ok := accentFolds[r]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+//比對用的球員名稱，去掉重音、標點和 Jr.、III 這種後綴
+func normalizeName(name string) string {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(foldAccents(name))) {
+		w = strings.Trim(w, ".,'")
+		w = strings.NewReplacer(".", "", "'", "", "’", "").Replace(w)
+		switch w {
+		case "", "jr", "sr", "ii", "iii", "iv":
+			continue
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+//normalizeName 之後再套用暱稱表
+func playerMatchKey(name string) string {
+	key := normalizeName(name)
+	if v, ok := playerAliases[key]; ok {
+		return v
+	}
+	return key
+}
+
+//兩個 playerMatchKey 是不是同一個人的不同寫法：姓相同、名是另一個的開頭(eg: "nic" 和 "nicolas")，
+//或是差一個字母的拼法
+func fuzzyPlayerMatch(a, b string) bool {
+	aw, bw := strings.Fields(a), strings.Fields(b)
+	if len(aw) >= 2 && len(bw) >= 2 && aw[len(aw)-1] == bw[len(bw)-1] {
+		af, bf := aw[0], bw[0]
+		if len(af) >= 2 && len(bf) >= 2 && (strings.HasPrefix(af, bf) || strings.HasPrefix(bf, af)) {
+			return true
+		}
+	}
+	return len(a) >= 8 && len(b) >= 8 && editDistance(a, b) <= 1
+}
+
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur := make([]int, len(br)+1)
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(br)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func newPlayerRegistry() *PlayerRegistry {
+	return &PlayerRegistry{
+		players: make(map[string]*Player),
+		keys:    make(map[string][]string),
+		next:    1,
+		changed: make(map[string]bool),
+		claimed: make(map[string]string),
+	}
+}
+
+//開始比對新的一份名單
+func (r *PlayerRegistry) newBatch() {
+	r.claimed = make(map[string]string)
+}
+
+//記下這份名單內 id 是哪一隊的
+func (r *PlayerRegistry) claim(id, team string) {
+	if id != "" && team != "" {
+		r.claimed[id] = team
+	}
+}
+
+//從資料庫讀出所有球員
+func loadPlayerRegistry(tx *bolt.Tx) (*PlayerRegistry, error) {
+	r := newPlayerRegistry()
+	err := tx.Bucket(bucketPlayers).ForEach(func(k, v []byte) error {
+		var p Player
+		if err := json.Unmarshal(v, &p); err != nil {
+			return err
+		}
+		r.add(&p)
+
+		var n int
+		if _, err := fmt.Sscanf(p.ID, "P%d", &n); err == nil && n >= r.next {
+			r.next = n + 1
+		}
+		return nil
+	})
+	return r, err
+}
+
+//把新增或改過的球員寫回資料庫
+func (r *PlayerRegistry) save(tx *bolt.Tx) error {
+	b := tx.Bucket(bucketPlayers)
+	for id := range r.changed {
+		if err := putJSON(b, id, r.players[id]); err != nil {
+			return err
+		}
+	}
+	r.changed = make(map[string]bool)
+	return nil
+}
+
+func (r *PlayerRegistry) add(p *Player) {
+	r.players[p.ID] = p
+	r.index(p.ID, p.Name)
+	for _, alias := range p.Aliases {
+		r.index(p.ID, alias)
+	}
+}
+
+func (r *PlayerRegistry) index(id, name string) {
+	key := playerMatchKey(name)
+	if key == "" {
+		return
+	}
+	for _, v := range r.keys[key] {
+		if v == id {
+			return
+		}
+	}
+	r.keys[key] = append(r.keys[key], id)
+}
+
+//用編號取得球員
+func (r *PlayerRegistry) Player(id string) (Player, bool) {
+	p, ok := r.players[id]
+	if !ok {
+		return Player{}, false
+	}
+	return *p, true
+}
+
+//找名稱或別名完全一樣的球員，team 是空字串時不看隊伍
+//
+//只有一個球員叫這個名稱時不管隊伍都是他，換隊還是同一個編號；
+//真的有同名的球員時才用隊伍分，同一份名單內已經對應到別隊的也不算
+func (r *PlayerRegistry) Lookup(name, team string) (string, bool) {
+	key := playerMatchKey(name)
+	if key == "" {
+		return "", false
+	}
+
+	ids := r.keys[key]
+	if team == "" {
+		if len(ids) == 1 {
+			return ids[0], true
+		}
+		return "", false
+	}
+
+	var candidates []string
+	for _, id := range ids {
+		if other, ok := r.claimed[id]; ok && !sameTeam(other, team) {
+			continue
+		}
+		if r.players[id].Team == "" || sameTeam(r.players[id].Team, team) {
+			return id, true
+		}
+		candidates = append(candidates, id)
+	}
+	if len(ids) == 1 && len(candidates) == 1 {
+		return candidates[0], true
+	}
+	return "", false
+}
+
+//Lookup 找不到時用 fuzzyPlayerMatch 猜，只有一個候選人才算
+//
+//猜的結果只在這次執行用，不會存成別名，確定是同一人再用 players alias 加上
+func (r *PlayerRegistry) Guess(name, team string) (string, bool) {
+	key := playerMatchKey(name)
+	if key == "" {
+		return "", false
+	}
+
+	var candidates []string
+	for id, p := range r.players {
+		if team != "" && p.Team != "" && !sameTeam(p.Team, team) {
+			continue
+		}
+		for _, v := range append([]string{p.Name}, p.Aliases...) {
+			if fuzzyPlayerMatch(key, playerMatchKey(v)) {
+				candidates = append(candidates, id)
+				break
+			}
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	return "", false
+}
+
+//找名稱完全一樣的球員，找不到就建立新的，不用猜的
+//
+//同一個名稱的不同寫法(重音、Jr.)記成別名；Lookup 對應到的球員換隊時更新成新的隊伍
+func (r *PlayerRegistry) Register(name, team string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+
+	id, ok := r.Lookup(name, team)
+	if !ok {
+		id = fmt.Sprintf("P%05d", r.next)
+		r.next++
+		r.add(&Player{ID: id, Name: name, Team: team, CreatedAt: time.Now()})
+		r.changed[id] = true
+		r.claim(id, team)
+		return id
+	}
+
+	p := r.players[id]
+	if team != "" && !sameTeam(p.Team, team) {
+		p.Team = team
+		r.changed[id] = true
+	}
+	r.claim(id, team)
+	if r.addAlias(p, name) {
+		r.changed[id] = true
+	}
+	return id
+}
+
+//加入別名，已經有一樣的寫法就不加
+func (r *PlayerRegistry) addAlias(p *Player, alias string) bool {
+	if alias == p.Name {
+		return false
+	}
+	for _, v := range p.Aliases {
+		if v == alias {
+			return false
+		}
+	}
+	p.Aliases = append(p.Aliases, alias)
+	r.index(p.ID, alias)
+	return true
+}
+
+//所有球員，依編號排序
+func (r *PlayerRegistry) Players() (result []Player) {
+	for _, p := range r.players {
+		result = append(result, *p)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+//幫傷兵名單填上球員編號，名稱統一成登記的寫法
+//
+//只讀資料庫，沒登記過的球員保留原本的名稱；資料庫打不開時也是
+func identifyInjuries(sets ...[]Injury) {
+	if err := withStore(func(s *Store) error {
+		r, err := s.Players()
+		if err != nil {
+			return err
+		}
+		r.identifyInjuries(sets, false)
+		return nil
+	}); err != nil {
+		fmt.Println("球員對應失敗:", err)
+	}
+}
+
+//和 identifyInjuries 一樣，沒登記過的球員順便登記，給傷兵快照用
+func registerInjuries(sets ...[]Injury) {
+	if err := withStore(func(s *Store) error {
+		return s.UpdatePlayers(func(r *PlayerRegistry) error {
+			r.identifyInjuries(sets, true)
+			return nil
+		})
+	}); err != nil {
+		fmt.Println("球員對應失敗:", err)
+	}
+}
+
+//先找完全一樣的名稱，再用猜的，register 時都找不到才登記新球員
+func (r *PlayerRegistry) identifyInjuries(sets [][]Injury, register bool) {
+	for _, set := range sets {
+		r.newBatch()
+		for i := range set {
+			id, ok := r.Lookup(set[i].Player, set[i].Team)
+			if ok && register {
+				id = r.Register(set[i].Player, set[i].Team)
+			}
+			if !ok {
+				id, ok = r.Guess(set[i].Player, set[i].Team)
+			}
+			if !ok && register {
+				id, ok = r.Register(set[i].Player, set[i].Team), true
+			}
+			if p, found := r.Player(id); ok && found {
+				r.claim(id, set[i].Team)
+				set[i].PlayerID = id
+				set[i].Player = p.Name
+			}
+		}
+	}
+}
+
+//scanNBA players list [-team name] [-season 2022-23] | alias name alias | show name
+func playersCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("用法: scanNBA players list|alias|show")
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = playersList(args[1:])
+	case "alias":
+		if len(args) < 3 {
+			fmt.Println("用法: scanNBA players alias 球員名稱或編號 別名")
+			os.Exit(2)
+		}
+		err = playersAlias(args[1], args[2])
+	case "show":
+		if len(args) < 2 {
+			fmt.Println("用法: scanNBA players show 球員名稱或編號")
+			os.Exit(2)
+		}
+		err = playersShow(args[1])
+	default:
+		fmt.Println("未知的指令: players " + args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+//編號或名稱都可以
+func findPlayer(r *PlayerRegistry, s string) (Player, error) {
+	if p, ok := r.Player(s); ok {
+		return p, nil
+	}
+	if id, ok := r.Lookup(s, ""); ok {
+		p, _ := r.Player(id)
+		return p, nil
+	}
+	if ids := r.keys[playerMatchKey(s)]; len(ids) > 1 {
+		return Player{}, fmt.Errorf("有多位球員叫 %s: %s，請用編號", s, strings.Join(ids, ", "))
+	}
+	return Player{}, fmt.Errorf("找不到球員 %s", s)
+}
+
+func (p Player) Text() string {
+	result := fmt.Sprintf("%s  %-25s %s", p.ID, p.Name, p.Team)
+	if len(p.Aliases) > 0 {
+		result = result + "  (" + strings.Join(p.Aliases, ", ") + ")"
+	}
+	return result
+}
+
+func playersList(args []string) error {
+	fs := flag.NewFlagSet("players list", flag.ExitOnError)
+	team := fs.String("team", "", "只列出某隊")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	from, to, err := seasonRange()
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(s *Store) error {
+		r, err := s.Players()
+		if err != nil {
+			return err
+		}
+
+		//有 -season 時只列出那一季資料庫內的比賽出現過的球員
+		var seen map[string]bool
+		if from != "" {
+			seen = make(map[string]bool)
+			err = s.EachGame(func(date string, g Game) error {
+				if !inRange(date, from, to) {
+					return nil
+				}
+				for _, t := range []GameTeam{g.AwayTeam, g.HomeTeam} {
+					for _, leader := range t.Leaders() {
+						if id, ok := r.Lookup(leader.Name(), t.FullName()); ok {
+							seen[id] = true
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, p := range r.Players() {
+			if *team != "" && !sameTeam(p.Team, *team) {
+				continue
+			}
+			if seen != nil && !seen[p.ID] {
+				continue
+			}
+			fmt.Println(p.Text())
+		}
+		return nil
+	})
+}
+
+//手動加上別名，eg: titan007 的中文名稱
+func playersAlias(name, alias string) error {
+	return withStore(func(s *Store) error {
+		return s.UpdatePlayers(func(r *PlayerRegistry) error {
+			p, err := findPlayer(r, name)
+			if err != nil {
+				return err
+			}
+			for _, other := range r.keys[playerMatchKey(alias)] {
+				if other != p.ID {
+					return fmt.Errorf("%s 已經是 %s 的名稱", alias, other)
+				}
+			}
+			if r.addAlias(r.players[p.ID], alias) {
+				r.changed[p.ID] = true
+			}
+			fmt.Println(r.players[p.ID].Text())
+			return nil
+		})
+	})
+}
+
+func playersShow(name string) error {
+	return withStore(func(s *Store) error {
+		r, err := s.Players()
+		if err != nil {
+			return err
+		}
+		p, err := findPlayer(r, name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(p.Text())
+
+		impacts, err := s.Impacts()
+		if err != nil {
+			return err
+		}
+		if v, ok := impacts[p.ID]; ok {
+			fmt.Printf("影響力 %.1f (%s)\n", v.Score(), v.Source)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//nba.com 的 seasonType，也是 gameId 的第三碼
+const (
+	SeasonPreseason = "1"
+	SeasonRegular   = "2"
+	SeasonAllStar   = "3"
+	SeasonPlayoffs  = "4"
+	SeasonPlayIn    = "5"
+)
+
+//報告是否略過季後賽「如果需要」才打的比賽
+var skipIfNecessary bool
+
+//比賽的 seasonType，daily.json 沒有就從 gameId 判斷，eg: "0042200123" -> "4"
+func (g Game) SeasonType() string {
+	if g.Profile.SeasonType != "" {
+		return g.Profile.SeasonType
+	}
+	if len(g.Profile.GameID) >= 3 {
+		return g.Profile.GameID[2:3]
+	}
+	return ""
+}
+
+//季後賽和附加賽
+func (g Game) Postseason() bool {
+	t := g.SeasonType()
+	return t == SeasonPlayoffs || t == SeasonPlayIn
+}
+
+//季後賽系列賽的第幾場，gameId 最後一碼，eg: "0042200123" 是第一輪第二組的第 3 場，不是季後賽回傳 0
+func (g Game) GameNumber() int {
+	if g.SeasonType() != SeasonPlayoffs || len(g.Profile.GameID) != 10 {
+		return 0
+	}
+	n, _ := strconv.Atoi(g.Profile.GameID[9:])
+	return n
+}
+
+//系列賽的狀況，eg: "LAL leads 2-1"，daily.json 沒給就是空白
+func (g Game) Series() string {
+	if s, ok := g.SeriesText.(string); ok {
+		return s
+	}
+	if s, ok := g.HomeTeam.Matchup.SeriesText.(string); ok {
+		return s
+	}
+	return ""
+}
+
+//比賽所屬的 titan007 球季，用 gameId 第四、五碼的年份，eg: "0042200123" -> "22-23"
+func gameSeason(g Game) string {
+	if len(g.Profile.GameID) >= 5 {
+		if year, err := strconv.Atoi(g.Profile.GameID[3:5]); err == nil {
+			return fmt.Sprintf("%02d-%02d", year, (year+1)%100)
+		}
+	}
+	t, _ := time.Parse("2006-01-02T15:04", g.Profile.DateTimeEt)
+	return dishSeason(t)
+}
+
+func seasonTypeLabel(t string) string {
+	switch t {
+	case SeasonPreseason:
+		return "季前賽"
+	case SeasonRegular:
+		return "例行賽"
+	case SeasonAllStar:
+		return "明星賽"
+	case SeasonPlayoffs:
+		return "季後賽"
+	case SeasonPlayIn:
+		return "附加賽"
+	}
+	return ""
+}
+
+//某季 titan007 季後賽(含附加賽)的所有比賽，季後賽從四月開始，最晚可能打到十月(2020)
+func loadPlayoffMatches(season string) (result []TitanMatch, err error) {
+	start, err := seasonStart(season)
+	if err != nil {
+		return nil, err
+	}
+
+	for month := start.AddDate(0, 6, 0); month.Before(start.AddDate(1, 1, 0)) && month.Before(time.Now()); month = month.AddDate(0, 1, 0) {
+		matches, err := loadTitanKindMatches(season, titanPlayoffs, month)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, matches...)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+//報告要顯示的比賽，有設定 skipIfNecessary 時略過「如果需要」的比賽
+func reportGames(s Schedule) (result []Game) {
+	for _, v := range s.Payload.Date.Games {
+		if skipIfNecessary && v.IfNecessary {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
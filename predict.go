@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+//預測模型，輸入兩隊的資料和盤口，輸出主隊的預估淨勝分和過盤機率
+type Predictor interface {
+	Name() string
+	Predict(in PredictInput) Prediction
+}
+
+//預測需要的資料，兩隊只會用到 Record、Rest、MissingImpact 和 ATS
+type PredictInput struct {
+	GameID  string
+	Date    string
+	Home    TeamReport
+	Away    TeamReport
+	Line    float64 //主隊讓分，-5.5 表示主隊讓 5.5 分
+	HasLine bool
+}
+
+//某個模型對某場比賽的預測，存起來之後比對結果
+type Prediction struct {
+	GameID    string    `json:"gameId"`
+	Date      string    `json:"date"`
+	Model     string    `json:"model"`
+	AwayTeam  string    `json:"awayTeam"`
+	HomeTeam  string    `json:"homeTeam"`
+	Margin    float64   `json:"margin"` //主隊預估贏幾分，負數表示客隊贏
+	Line      float64   `json:"line"`
+	HasLine   bool      `json:"hasLine"`
+	CoverProb float64   `json:"coverProb"` //主隊過盤的機率，沒有盤口時是主隊贏球的機率
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//報告預設用的模型
+var defaultPredictor Predictor = baselineModel{
+	HomeCourt:    2.5,
+	WinPctWeight: 30,
+	BackToBack:   2,
+	RestBonus:    0.5,
+	ImpactWeight: 0.5,
+	ATSWeight:    2,
+	Sigma:        12,
+}
+
+//簡單的線性模型，每個係數都是「幾分」
+type baselineModel struct {
+	HomeCourt    float64 //主場優勢
+	WinPctWeight float64 //勝率差 1.0 等於幾分
+	BackToBack   float64 //背靠背扣幾分
+	RestBonus    float64 //休息兩天以上加幾分
+	ImpactWeight float64 //影響力 1 等於幾分
+	ATSWeight    float64 //過盤率比五成每多 50% 加幾分
+	Sigma        float64 //實際淨勝分和預估的標準差
+}
+
+func (m baselineModel) Name() string {
+	return "baseline"
+}
+
+//單隊的分數調整
+func (m baselineModel) adjust(t TeamReport) (result float64) {
+	switch {
+	case t.Rest.BackToBack:
+		result = result - m.BackToBack
+	case t.Rest.RestDays == -1 || t.Rest.RestDays >= 2:
+		result = result + m.RestBonus
+	}
+
+	result = result - t.MissingImpact*m.ImpactWeight
+
+	if len(t.ATS.Games) > 0 {
+		result = result + (t.ATS.CoverPct-50)/50*m.ATSWeight
+	}
+	return result
+}
+
+func (m baselineModel) Predict(in PredictInput) Prediction {
+	margin := m.HomeCourt
+	margin = margin + (in.Home.Record.WinPct()-in.Away.Record.WinPct())*m.WinPctWeight
+	margin = margin + m.adjust(in.Home) - m.adjust(in.Away)
+
+	line := 0.0
+	if in.HasLine {
+		line = in.Line
+	}
+
+	return Prediction{
+		GameID:    in.GameID,
+		Date:      in.Date,
+		Model:     m.Name(),
+		AwayTeam:  in.Away.Name,
+		HomeTeam:  in.Home.Name,
+		Margin:    margin,
+		Line:      in.Line,
+		HasLine:   in.HasLine,
+		CoverProb: normalCDF((margin + line) / m.Sigma),
+		CreatedAt: time.Now(),
+	}
+}
+
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+//建議的一方，機率在 pct 以內視為沒有建議
+func (p Prediction) Pick(pct float64) string {
+	switch {
+	case p.CoverProb >= 0.5+pct:
+		return p.HomeTeam
+	case p.CoverProb <= 0.5-pct:
+		return p.AwayTeam
+	}
+	return ""
+}
+
+//eg: "預測 主隊 +3.2 分 主隊 -2.5 過盤機率 56.1%"
+func (p Prediction) Text() string {
+	if p.Model == "" {
+		return "無預測"
+	}
+	msg := fmt.Sprintf("預測 主隊 %+.1f 分", p.Margin)
+	if !p.HasLine {
+		return msg + fmt.Sprintf(" 無盤口 主隊勝率 %.1f%%", p.CoverProb*100)
+	}
+	return msg + fmt.Sprintf(" 主隊 %+.1f 過盤機率 %.1f%%", p.Line, p.CoverProb*100)
+}
+
+//這場比賽的主隊讓分，自己記錄的優先，沒有就用 titan007
+func gameLine(spreads map[string]Spread, matches []TitanMatch, g Game) (float64, bool) {
+	if spread, ok := spreads[g.Profile.GameID]; ok {
+		return spread.Line, true
+	}
+	if m, ok := findTitanMatch(matches, g); ok && m.HasLine {
+		return -m.Line, true
+	}
+	return 0, false
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//簡單的記憶體快取，過期後下一次讀取會重新抓
+//
+//抓資料時不會鎖住整個快取，同一個 key 同時間只抓一次，其他人等它抓完
+type ttlCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	items   map[string]cacheItem
+	loading map[string]*cacheCall
+}
+
+type cacheItem struct {
+	value   interface{}
+	expires time.Time
+}
+
+//進行中的讀取
+type cacheCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl, items: make(map[string]cacheItem), loading: make(map[string]*cacheCall)}
+}
+
+//取得 key 的值，沒有或過期就用 load 重新抓，抓失敗不會存進快取
+func (c *ttlCache) get(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if item, ok := c.items[key]; ok && time.Now().Before(item.expires) {
+		c.mu.Unlock()
+		return item.value, nil
+	}
+	if call, ok := c.loading[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.loading[key] = call
+	c.mu.Unlock()
+
+	//load panic 時也要清掉進行中的讀取，不然之後同一個 key 都會卡住
+	defer func() {
+		c.mu.Lock()
+		delete(c.loading, key)
+		if call.err == nil {
+			c.items[key] = cacheItem{value: call.value, expires: time.Now().Add(c.ttl)}
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = c.load(key, load)
+
+	return call.value, call.err
+}
+
+//執行 load，panic 換成錯誤
+func (c *ttlCache) load(key string, load func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, fmt.Errorf("讀取 %s 失敗: %v", key, r)
+		}
+	}()
+	return load()
+}
+
+//PKTeam 和 serve 共用的資料來源，同一段時間內不會重複抓同一個網址
+var (
+	scheduleCache = newCache(time.Minute)
+	injuryCache   = newCache(5 * time.Minute)
+	dishCache     = newCache(30 * time.Minute)
+)
+
+func loadSchedule(date string) (Schedule, error) {
+	v, err := scheduleCache.get(date, func() (interface{}, error) {
+		return getSchedule(date)
+	})
+	if err != nil {
+		return Schedule{}, err
+	}
+	return v.(Schedule), nil
+}
+
+func loadInjuries() ([]Injury, error) {
+	v, err := injuryCache.get("injuries", func() (interface{}, error) {
+		return fetchAllInjuries(injurySources())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]Injury), nil
+}
+
+func loadDishData(season string) ([]byte, error) {
+	v, err := dishCache.get(season, func() (interface{}, error) {
+		return loadSeasonFile(season, "l1.js", func() ([]byte, error) {
+			return fetchDishData(season)
+		}, validDishData)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+//titan007 檔案的快取目錄，可以用 SCANNBA_CACHE 改
+func cacheDir() string {
+	if dir := os.Getenv("SCANNBA_CACHE"); dir != "" {
+		return dir
+	}
+	return "titan-cache"
+}
+
+//已經結束的球季永久快取在硬碟，之後都不再抓，還在進行的球季每次都用 fetch
+//
+//valid 檢查內容是完整的才寫入，下載到一半或空的回應不能永久留著；舊的快取不完整也會重抓
+func loadSeasonFile(season, name string, fetch func() ([]byte, error), valid func([]byte) error) ([]byte, error) {
+	if !seasonClosed(season) {
+		return fetch()
+	}
+
+	path := filepath.Join(cacheDir(), season, name)
+	if body, err := ioutil.ReadFile(path); err == nil && valid(body) == nil {
+		return body, nil
+	}
+
+	body, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if err := valid(body); err != nil {
+		fmt.Println(season, name, "內容不完整，不寫入快取:", err)
+		return body, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		ioutil.WriteFile(path, body, 0644)
+	}
+	return body, nil
+}
+
+//titan007 的 js 檔最後是 "];"，沒有就是沒下載完
+func completeJS(body []byte) error {
+	s := strings.TrimSpace(string(body))
+	if !strings.HasSuffix(s, ";") && !strings.HasSuffix(s, "]") {
+		return fmt.Errorf("檔案結尾不完整")
+	}
+	return nil
+}
+
+//完整的球季每隊都有一列，少於 30 隊就是不完整
+func validTeamRows(body []byte, minFields int) error {
+	if err := completeJS(body); err != nil {
+		return err
+	}
+	var rows int
+	for _, row := range jsArrays(string(body)) {
+		if len(row) > minFields {
+			rows++
+		}
+	}
+	if rows < 30 {
+		return fmt.Errorf("只有 %d 隊的資料", rows)
+	}
+	return nil
+}
+
+func validDishData(body []byte) error {
+	if !strings.Contains(string(body), "arrTeam") {
+		return fmt.Errorf("找不到 arrTeam")
+	}
+	return validTeamRows(body, dishFieldRecent)
+}
+
+//已結束球季的某個月至少要有一場打完的比賽
+func validTitanMatches(body []byte) error {
+	if err := completeJS(body); err != nil {
+		return err
+	}
+	for _, v := range jsArrays(string(body)) {
+		if len(v) > titanFieldTotal && v[titanFieldState] == "-1" {
+			return nil
+		}
+	}
+	return fmt.Errorf("沒有打完的比賽")
+}
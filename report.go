@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+//當天所有比賽的整理結果，給通知和其他輸出使用
+type Report struct {
+	Date          string         `json:"date"`
+	Games         []GameReport   `json:"games"`
+	InjuryChanges []InjuryChange `json:"injuryChanges,omitempty"`
+}
+
+//單場比賽
+type GameReport struct {
+	GameID    string     `json:"gameId"`
+	StartTime string     `json:"startTime"` //台灣時間 15:04
+	Away      TeamReport `json:"away"`
+	Home      TeamReport `json:"home"`
+
+	SeasonType  string `json:"seasonType"`
+	GameNumber  int    `json:"gameNumber,omitempty"` //季後賽系列賽的第幾場
+	Series      string `json:"series,omitempty"`     //eg: "LAL leads 2-1"
+	IfNecessary bool   `json:"ifNecessary"`
+	Notice      string `json:"notice,omitempty"` //缺少資料的原因，eg: 明星賽、季前賽
+
+	HeadToHead []Meeting `json:"headToHead"` //這一季和上一季的交手，最新的在前面
+
+	Prediction Prediction `json:"prediction"`
+}
+
+//單支隊伍的傷兵和過盤資訊
+type TeamReport struct {
+	Name    string   `json:"name"`
+	Chinese string   `json:"chinese"`
+	Injury  []string `json:"injury"`
+	Comment string   `json:"comment"` //GetInjuryComment 的中文結果，沒有傷兵時為 "xx-全陣容"
+	Dish    string   `json:"dish"`    //近期過盤的中文，eg: "贏(-5.5 +8),走(+3.0 -3),輸"
+
+	DishGames []DishResult `json:"dishGames"`
+
+	ATS    ATSStats      `json:"ats"`
+	Totals TotalsSummary `json:"totals"`
+	Rest   RestInfo      `json:"rest"`
+	Record TeamRecord    `json:"record"`
+
+	MissingImpact float64 `json:"missingImpact"` //缺陣球員的影響力總和
+}
+
+//取得隊伍中文名稱，快艇在 teamMap 內是用 "LA Clippers"
+func chineseName(teamMap map[string]string, team string) string {
+	if team == "Los Angeles Clippers" {
+		team = "LA Clippers"
+	}
+	if name, ok := teamMap[team]; ok {
+		return name
+	}
+	return team
+}
+
+//傷兵影響力，沒有影響就不顯示
+func impactText(v float64) string {
+	if v <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (影響 %.1f)", v)
+}
+
+//比賽標題，eg: "1. 湖人 @ 勇士(主) 10:30"
+func (g GameReport) Title(i int) string {
+	return fmt.Sprintf("%d. %s @ %s(主) %s", i+1, g.Away.Chinese, g.Home.Chinese, g.StartTime) + g.stageText()
+}
+
+//季後賽的場次和系列賽狀況，例行賽是空白，eg: " 季後賽 G3 LAL leads 2-1 (如果需要)"
+func (g GameReport) stageText() (msg string) {
+	if g.SeasonType != SeasonPlayoffs && g.SeasonType != SeasonPlayIn {
+		return ""
+	}
+	msg = " " + seasonTypeLabel(g.SeasonType)
+	if g.GameNumber > 0 {
+		msg = msg + fmt.Sprintf(" G%d", g.GameNumber)
+	}
+	if g.Series != "" {
+		msg = msg + " " + g.Series
+	}
+	if g.IfNecessary {
+		msg = msg + " (如果需要)"
+	}
+	return msg
+}
+
+//比賽的中文傷兵和過盤內容
+func (g GameReport) Comment() string {
+	msg := g.Away.Chinese + " " + g.Away.Record.Text() + " / " + g.Home.Chinese + " " + g.Home.Record.Text() + "\n"
+	msg = msg + g.Away.Comment + impactText(g.Away.MissingImpact) + "\n"
+	msg = msg + g.Home.Comment + impactText(g.Home.MissingImpact) + "\n"
+	msg = msg + g.Away.Chinese + " 近期過盤: " + g.Away.Dish + "\n"
+	msg = msg + g.Home.Chinese + " 近期過盤: " + g.Home.Dish + "\n"
+	msg = msg + g.Away.Chinese + " 近" + fmt.Sprint(len(g.Away.ATS.Games)) + "場讓分: " + g.Away.ATS.Text() + "\n"
+	msg = msg + g.Home.Chinese + " 近" + fmt.Sprint(len(g.Home.ATS.Games)) + "場讓分: " + g.Home.ATS.Text() + "\n"
+	msg = msg + g.Away.Chinese + " 近期大小分: " + g.Away.Totals.Text() + "\n"
+	msg = msg + g.Home.Chinese + " 近期大小分: " + g.Home.Totals.Text() + "\n"
+	msg = msg + g.Away.Chinese + " " + g.Away.Rest.Text() + "\n"
+	msg = msg + g.Home.Chinese + " " + g.Home.Rest.Text() + "\n"
+	if g.Notice != "" {
+		msg = msg + g.Notice + "\n"
+	}
+	msg = msg + g.Prediction.Text()
+	if pick := g.Prediction.Pick(0.05); pick != "" {
+		msg = msg + " 傾向" + chineseName(TeamInit(), pick)
+	}
+
+	teamMap := TeamInit()
+	for i, m := range g.HeadToHead {
+		//通知只放最近三次
+		if i == 3 {
+			break
+		}
+		msg = msg + "\n對戰: " + m.Text(teamMap)
+	}
+	return msg
+}
+
+//把賽程整理成報告，傷兵和過盤資料都從共用的 provider 取得，讓分和大小分看最近 lookback 場
+func buildReport(date string, schedule Schedule, lookback int) (report Report, err error) {
+	var layout string = "2006-01-02T15:04"
+	teamMap := TeamInit()
+	report.Date = date
+
+	games := reportGames(schedule)
+	if len(games) == 0 {
+		return report, nil
+	}
+	season := gameSeason(games[0])
+	if seasonOverride != "" {
+		season = seasonOverride
+	}
+	var hasTitan bool
+	for _, v := range games {
+		if _, ok := titanKind(v.SeasonType()); ok && !v.SummerLeague() {
+			hasTitan = true
+		}
+	}
+
+	injuries, err := loadInjuries()
+	if err != nil {
+		return report, err
+	}
+	impacts := loadImpacts()
+
+	//讓分、大小分和對戰紀錄只是參考，titan007 或資料庫讀不到時報告照樣產生
+	var matches []TitanMatch
+	if hasTitan {
+		if matches, err = loadSeasonMatches(season); err != nil {
+			fmt.Println("titan007 比賽資料錯誤:", err)
+		}
+	}
+	//季後賽的讓分和大小分只看季後賽
+	var playoffMatches []TitanMatch
+	for _, v := range games {
+		if v.Postseason() {
+			if playoffMatches, err = loadPlayoffMatches(season); err != nil {
+				fmt.Println("titan007 季後賽資料錯誤:", err)
+			}
+			break
+		}
+	}
+	//bs1.js 是例行賽的大小分，用來驗證 matchResult 的盤口欄位
+	var totalsRecords map[string][]string
+	if hasTitan {
+		if totalsRecords, err = loadTotalsRecords(season); err != nil {
+			fmt.Println("titan007 大小分資料錯誤:", err)
+		}
+	}
+	h2hMatches, err := loadH2HMatches(season)
+	if err != nil {
+		fmt.Println("titan007 對戰資料錯誤:", err)
+	}
+	var scores []FinalScore
+	spreads := make(map[string]Spread)
+	if err := withStore(func(s *Store) error {
+		list, err := s.Spreads()
+		if err != nil {
+			return err
+		}
+		for _, v := range list {
+			spreads[v.GameID] = v
+		}
+		scores, err = s.Scores()
+		return err
+	}); err != nil {
+		fmt.Println("讀取資料庫失敗:", err)
+	}
+	since, _ := seasonStart(previousSeason(season))
+	slates, err := recentSlates(date)
+	if err != nil {
+		fmt.Println("前幾天的賽程錯誤:", err)
+	}
+
+	for _, v := range games {
+		t, _ := time.Parse(layout, v.Profile.DateTimeEt)
+
+		game := GameReport{
+			GameID:      v.Profile.GameID,
+			StartTime:   t.Add(time.Hour * 13).Format("15:04"),
+			SeasonType:  v.SeasonType(),
+			GameNumber:  v.GameNumber(),
+			Series:      v.Series(),
+			IfNecessary: v.IfNecessary,
+			Notice:      dataNotice(v),
+		}
+		kind, hasLines := titanKind(v.SeasonType())
+		hasLines = hasLines && !v.SummerLeague()
+		atsMatches, records := matches, totalsRecords
+		switch {
+		case !hasLines:
+			atsMatches = nil
+		case kind == titanPlayoffs:
+			atsMatches, records = playoffMatches, nil
+		}
+
+		for _, side := range []struct {
+			team   *TeamReport
+			source GameTeam
+		}{{&game.Away, v.AwayTeam}, {&game.Home, v.HomeTeam}} {
+			name := side.source.FullName()
+			franchise := side.source.Franchise() && !v.SummerLeague()
+
+			//明星隊和夏季聯賽的隊伍沒有傷兵和過盤資料
+			var teamInjury []Injury
+			var dish []DishResult
+			if franchise {
+				teamInjury = teamInjuries(injuries, name)
+				sortByImpact(impacts, teamInjury)
+			}
+			if franchise && hasLines {
+				//titan007 抓不到時只少了近期過盤，報告照樣產生
+				if result, err := dishResults(season, kind, name, lookback); err != nil {
+					fmt.Println(name, "近期過盤錯誤:", err)
+				} else {
+					dish = result
+				}
+			}
+
+			*side.team = TeamReport{
+				Name:    name,
+				Chinese: chineseName(teamMap, name),
+				Injury:  injuryLines(teamInjury),
+				Comment: injuryComment(teamMap, name, teamInjury),
+				Dish:    dishText(dish),
+				ATS:     teamATS(atsMatches, name, lookback),
+				Totals:  teamTotalsSummary(atsMatches, records, name, lookback),
+				Rest:    teamRest(slates, name, v.Profile.ArenaLocation),
+				Record:  teamRecord(date, side.source),
+
+				DishGames:     dish,
+				MissingImpact: missingImpact(impacts, teamInjury),
+			}
+			//季前賽和明星賽改用資料庫手動記錄的讓分
+			if !hasLines && !v.SummerLeague() {
+				side.team.ATS = storedATS(spreads, name, v.SeasonType(), lookback)
+			}
+			switch {
+			case !franchise:
+				side.team.Comment = side.team.Chinese + "-無傷兵資料"
+			case len(teamInjury) == 0:
+				side.team.Comment = side.team.Chinese + "-全陣容"
+			}
+		}
+
+		game.HeadToHead = headToHead(scores, h2hMatches, game.Away.Name, game.Home.Name, since.Format("2006-01-02"), date)
+
+		//明星賽和夏季聯賽預測沒有意義
+		if v.SeasonType() != SeasonAllStar && !v.SummerLeague() {
+			in := PredictInput{GameID: game.GameID, Date: date, Home: game.Home, Away: game.Away}
+			in.Line, in.HasLine = gameLine(spreads, atsMatches, v)
+			game.Prediction = defaultPredictor.Predict(in)
+		}
+
+		report.Games = append(report.Games, game)
+	}
+
+	return report, nil
+}
+
+//PKTeam 印在 console 的內容，commentMsg 是中文摘要，msg 是每場比賽的詳細資料
+func consoleText(r *Report) (commentMsg, msg string) {
+	msg = "今天 " + r.Date + " 有 " + fmt.Sprint(len(r.Games)) + " 場比賽 \n"
+
+	for i, g := range r.Games {
+		msg = msg + gameConsoleText(i, g)
+
+		if len(g.Away.Injury) == 0 {
+			commentMsg = commentMsg + g.Away.Comment + " /"
+		} else {
+			commentMsg = commentMsg + g.Away.Comment + "; "
+		}
+		commentMsg = commentMsg + g.Home.Comment + "   " + "\n\n"
+	}
+
+	return commentMsg, msg
+}
+
+//單場比賽的詳細資料
+func gameConsoleText(i int, g GameReport) (msg string) {
+	msg = msg + fmt.Sprint(i+1) + ". " + g.Away.Name + "  " + g.StartTime + "  " + g.Home.Name + "(主)  " + g.stageText() + "\n"
+	msg = msg + "   " + g.Away.Name + " " + g.Away.Record.Text() + " / " + g.Home.Name + " " + g.Home.Record.Text() + "\n"
+
+	msg = msg + "\n  ---------------------------------\n"
+	msg = msg + "  " + g.Away.Name + " injury 名單 \n"
+
+	if len(g.Away.Injury) == 0 {
+		msg = msg + "  沒有傷兵\n\n"
+	} else {
+		for _, v := range g.Away.Injury {
+			msg = msg + "  " + v + "\n"
+		}
+		msg = msg + fmt.Sprintf("  傷兵影響: %.1f\n", g.Away.MissingImpact)
+	}
+
+	msg = msg + "\n  ---------------------------------\n"
+
+	msg = msg + "  " + g.Home.Name + " injury 名單 \n"
+	if len(g.Home.Injury) == 0 {
+		msg = msg + "  沒有傷兵\n\n"
+	} else {
+		for _, v := range g.Home.Injury {
+			msg = msg + "  " + v + "\n"
+		}
+		msg = msg + fmt.Sprintf("  傷兵影響: %.1f\n", g.Home.MissingImpact)
+		msg = msg + "\n"
+	}
+
+	msg = msg + "  " + g.Away.Name + " 近期過盤狀況: " + g.Away.Dish + "\n"
+	msg = msg + "  " + g.Home.Name + " 近期過盤狀況: " + g.Home.Dish + "\n"
+	msg = msg + "  " + g.Away.Name + " 近" + fmt.Sprint(len(g.Away.ATS.Games)) + "場讓分: " + g.Away.ATS.Text() + "\n"
+	msg = msg + "  " + g.Home.Name + " 近" + fmt.Sprint(len(g.Home.ATS.Games)) + "場讓分: " + g.Home.ATS.Text() + "\n"
+	msg = msg + "  " + g.Away.Name + " 近期大小分: " + g.Away.Totals.Text() + "\n"
+	msg = msg + "  " + g.Home.Name + " 近期大小分: " + g.Home.Totals.Text() + "\n"
+	msg = msg + "  " + g.Away.Name + " " + g.Away.Rest.Text() + "\n"
+	msg = msg + "  " + g.Home.Name + " " + g.Home.Rest.Text() + "\n"
+	if g.Notice != "" {
+		msg = msg + "  " + g.Notice + "\n"
+	}
+	msg = msg + "  " + g.Prediction.Text() + "\n"
+
+	if len(g.HeadToHead) == 0 {
+		msg = msg + "  對戰紀錄: 無\n\n"
+		return msg
+	}
+	teamMap := TeamInit()
+	msg = msg + "  對戰紀錄:\n"
+	for _, m := range g.HeadToHead {
+		msg = msg + "    " + m.Text(teamMap) + "\n"
+	}
+	msg = msg + "\n"
+	return msg
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+//某隊在比賽日前的休息和移動狀況
+type RestInfo struct {
+	RestDays   int     `json:"restDays"` //-1 表示前 7 天都沒有比賽
+	BackToBack bool    `json:"backToBack"`
+	GamesLast7 int     `json:"gamesLast7"`
+	LastGame   string  `json:"lastGame,omitempty"`
+	TravelKm   float64 `json:"travelKm"` //-1 表示不知道
+}
+
+//球場所在城市的經緯度，key 是 ArenaLocation 逗號前的城市名稱
+var arenaCoords = map[string][2]float64{
+	"atlanta":        {33.757, -84.396},
+	"boston":         {42.366, -71.062},
+	"brooklyn":       {40.683, -73.975},
+	"charlotte":      {35.225, -80.839},
+	"chicago":        {41.881, -87.674},
+	"cleveland":      {41.496, -81.688},
+	"dallas":         {32.790, -96.810},
+	"denver":         {39.749, -105.008},
+	"detroit":        {42.341, -83.055},
+	"san francisco":  {37.768, -122.388},
+	"houston":        {29.751, -95.362},
+	"indianapolis":   {39.764, -86.156},
+	"los angeles":    {34.043, -118.267},
+	"inglewood":      {33.945, -118.343},
+	"memphis":        {35.138, -90.051},
+	"miami":          {25.781, -80.188},
+	"milwaukee":      {43.045, -87.917},
+	"minneapolis":    {44.979, -93.276},
+	"new orleans":    {29.949, -90.082},
+	"new york":       {40.751, -73.994},
+	"oklahoma city":  {35.463, -97.515},
+	"orlando":        {28.539, -81.384},
+	"philadelphia":   {39.901, -75.172},
+	"phoenix":        {33.446, -112.071},
+	"portland":       {45.532, -122.667},
+	"sacramento":     {38.580, -121.500},
+	"san antonio":    {29.427, -98.438},
+	"toronto":        {43.643, -79.379},
+	"salt lake city": {40.768, -111.901},
+	"washington":     {38.898, -77.021},
+	"mexico city":    {19.404, -99.094},
+	"paris":          {48.839, 2.379},
+	"las vegas":      {36.103, -115.178},
+}
+
+func arenaCity(location string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(location, ",")[0]))
+}
+
+//兩個球場的距離(公里)，不知道位置回傳 -1
+func arenaDistance(from, to string) float64 {
+	a, ok1 := arenaCoords[arenaCity(from)]
+	b, ok2 := arenaCoords[arenaCity(to)]
+	if !ok1 || !ok2 {
+		return -1
+	}
+
+	const earthRadius = 6371.0
+	lat1, lat2 := a[0]*math.Pi/180, b[0]*math.Pi/180
+	dLat := (b[0] - a[0]) * math.Pi / 180
+	dLon := (b[1] - a[1]) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+//過去某天的賽程，資料庫有就用資料庫的，沒有就抓 daily.json 存起來
+func pastGames(date string) ([]Game, error) {
+	var games []Game
+	err := withStore(func(s *Store) (err error) {
+		games, err = s.Games(date)
+		return err
+	})
+	if err == nil && len(games) > 0 {
+		return games, nil
+	}
+
+	schedule, err := loadSchedule(date)
+	if err != nil {
+		return nil, err
+	}
+	saveToStore(func(s *Store) error {
+		return s.SaveSchedule(date, schedule)
+	})
+	return schedule.Payload.Date.Games, nil
+}
+
+//比賽日前 7 天的賽程，最近的在前面
+func recentSlates(date string) (result [][]Game, err error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= 7; i++ {
+		games, err := pastGames(day.AddDate(0, 0, -i).Format("2006-01-02"))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, games)
+	}
+	return result, nil
+}
+
+//某隊的休息天數、背靠背、近 7 天場數和從上一場移動的距離，slates 是 recentSlates 的結果
+func teamRest(slates [][]Game, team, arenaLocation string) RestInfo {
+	info := RestInfo{RestDays: -1, TravelKm: -1}
+
+	for i, games := range slates {
+		for _, g := range games {
+			if !sameTeam(g.HomeTeam.FullName(), team) && !sameTeam(g.AwayTeam.FullName(), team) {
+				continue
+			}
+
+			info.GamesLast7++
+			if info.RestDays == -1 {
+				info.RestDays = i
+				info.BackToBack = i == 0
+				info.LastGame = g.Profile.ArenaLocation
+				info.TravelKm = arenaDistance(g.Profile.ArenaLocation, arenaLocation)
+			}
+		}
+	}
+	return info
+}
+
+//eg: "背靠背第二場 近7天 4 場 移動 2,345 km"
+func (r RestInfo) Text() string {
+	var msg string
+	switch {
+	case r.RestDays == -1:
+		msg = "休息 7 天以上"
+	case r.BackToBack:
+		msg = "背靠背第二場"
+	default:
+		msg = fmt.Sprintf("休息 %d 天", r.RestDays)
+	}
+
+	msg = msg + fmt.Sprintf(" 近7天 %d 場", r.GamesLast7)
+	if r.TravelKm >= 0 {
+		msg = msg + fmt.Sprintf(" 移動 %s km", commaInt(int(math.Round(r.TravelKm))))
+	}
+	return msg
+}
+
+//1234567 -> "1,234,567"
+func commaInt(n int) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i = i - 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//nba.com 的比賽日是美東時間
+func gameDate(t time.Time) string {
+	//轉成UTC-4
+	zone := time.FixedZone("", -4*60*60)
+	return t.In(zone).Format("2006-01-02")
+}
+
+//取得某天(yyyy-mm-dd)的賽程
+func getSchedule(date string) (result Schedule, err error) {
+	//get data api url
+	url := "https://in.global.nba.com/stats2/scores/daily.json?gameDate=" + date + "&locale=en&tz=%2B8&countryCode=TW#"
+	resp, err := http.Get(url)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return result, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(body, &result)
+	return result, err
+}
+
+//隊伍全名，nba.com 的快艇城市是 "LA"
+func fullTeamName(city, name string) string {
+	if name == "Clippers" {
+		city = "Los Angeles"
+	}
+	return city + " " + name
+}
+
+//賽程上所有的隊伍
+func slateTeams(s Schedule) (result []string) {
+	for _, v := range s.Payload.Date.Games {
+		result = append(result, v.AwayTeam.FullName(), v.HomeTeam.FullName())
+	}
+	return result
+}
+
+//Boxscore.Status
+const (
+	StatusScheduled = "1"
+	StatusLive      = "2"
+	StatusFinal     = "3"
+)
+
+//比賽是否已經結束
+func (g Game) Final() bool {
+	return g.Boxscore.Status == StatusFinal
+}
+
+func (t GameTeam) FullName() string {
+	return fullTeamName(t.Profile.City, t.Profile.Name)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//nba.com 的 leagueId，夏季聯賽的 gameId 也是用這兩碼開頭
+const (
+	leagueNBA    = "00"
+	leagueSummer = "15"
+)
+
+//比賽對應的 titan007 matchResult 種類，季前賽和明星賽 titan007 沒有盤口回傳 false
+func titanKind(seasonType string) (int, bool) {
+	switch seasonType {
+	case SeasonRegular:
+		return titanRegular, true
+	case SeasonPlayoffs, SeasonPlayIn:
+		return titanPlayoffs, true
+	}
+	return 0, false
+}
+
+//夏季聯賽
+func (g Game) SummerLeague() bool {
+	if g.HomeTeam.Profile.LeagueID != "" && g.HomeTeam.Profile.LeagueID != leagueNBA {
+		return true
+	}
+	return len(g.Profile.GameID) >= 2 && g.Profile.GameID[:2] == leagueSummer
+}
+
+//30 支 NBA 球隊，明星隊、世界隊和夏季聯賽的隊伍都不算
+func (t GameTeam) Franchise() bool {
+	if t.Profile.IsAllStarTeam || !t.Profile.IsLeagueTeam {
+		return false
+	}
+	return t.Profile.LeagueID == "" || t.Profile.LeagueID == leagueNBA
+}
+
+//這場比賽缺少哪些資料，完整的比賽回傳空白
+func dataNotice(g Game) string {
+	switch {
+	case g.SummerLeague():
+		return "夏季聯賽沒有傷兵、過盤和讓分資料"
+	case g.SeasonType() == SeasonAllStar:
+		return "明星賽沒有傷兵和過盤資料，讓分只有資料庫手動記錄的(spread set)"
+	case g.SeasonType() == SeasonPreseason:
+		return "季前賽 titan007 沒有盤口，讓分只有資料庫手動記錄的(spread set)"
+	case !g.AwayTeam.Franchise() || !g.HomeTeam.Franchise():
+		return "對手不是 NBA 球隊，只有 NBA 球隊的資料"
+	}
+	return ""
+}
+
+//-season 指定的球季(titan007 格式 "22-23")，空白表示依日期判斷
+var seasonOverride string
+
+//解析 "2022-23"、"2022-2023"、"22-23" 或 "2022"，回傳 titan007 格式的 "22-23"
+func parseSeason(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	first := strings.Split(s, "-")[0]
+	year, err := strconv.Atoi(first)
+	if err != nil || (len(first) != 2 && len(first) != 4) {
+		return "", fmt.Errorf("球季格式錯誤: %s，要像 2022-23", s)
+	}
+	year = year % 100
+	season := fmt.Sprintf("%02d-%02d", year, (year+1)%100)
+
+	if parts := strings.Split(s, "-"); len(parts) == 2 {
+		second := parts[1]
+		if len(second) == 4 {
+			second = second[2:]
+		}
+		if second != season[3:] {
+			return "", fmt.Errorf("球季格式錯誤: %s，後面的年份要是隔年", s)
+		}
+	}
+	return season, nil
+}
+
+//讓 -season 可以直接用 flag.Var
+type seasonValue struct{}
+
+func (seasonValue) String() string {
+	return seasonOverride
+}
+
+func (seasonValue) Set(s string) (err error) {
+	seasonOverride, err = parseSeason(s)
+	return err
+}
+
+//每個指令共用的 -season
+func seasonFlag(fs *flag.FlagSet) {
+	fs.Var(seasonValue{}, "season", "球季，eg: 2022-23，空白表示依日期判斷")
+}
+
+//某個時間點的球季，有 -season 就用指定的
+func seasonOf(t time.Time) string {
+	if seasonOverride != "" {
+		return seasonOverride
+	}
+	return dishSeason(t)
+}
+
+//上一季，eg: "22-23" -> "21-22"
+func previousSeason(season string) string {
+	start, err := seasonStart(season)
+	if err != nil {
+		return season
+	}
+	return dishSeason(start.AddDate(-1, 0, 0))
+}
+
+//球季包含的美東日期範圍，和 dishSeason 一樣從九月算到隔年八月
+func seasonDates(season string) (from, to string, err error) {
+	start, err := seasonStart(season)
+	if err != nil {
+		return "", "", err
+	}
+	first := time.Date(start.Year(), time.September, 1, 0, 0, 0, 0, time.UTC)
+	return first.Format("2006-01-02"), first.AddDate(1, 0, -1).Format("2006-01-02"), nil
+}
+
+//已經結束的球季，titan007 的資料不會再變
+func seasonClosed(season string) bool {
+	start, err := seasonStart(season)
+	if err != nil {
+		return false
+	}
+	return dishSeason(time.Now()) != season && start.Before(time.Now())
+}
+
+//有 -season 時 date 要在那一季裡面
+func checkSeasonDate(date string) error {
+	from, to, err := seasonRange()
+	if err != nil {
+		return err
+	}
+	if !inRange(date, from, to) {
+		return fmt.Errorf("%s 不在 -season %s 的範圍 %s ~ %s", date, seasonOverride, from, to)
+	}
+	return nil
+}
+
+//-season 指定了目前以外的球季，今天的資料(傷兵、今天的賽程)不能當作那一季的
+func pastSeason() bool {
+	return seasonOverride != "" && seasonOverride != dishSeason(time.Now())
+}
+
+//只有目前資料的指令，-season 指定別季就回傳錯誤
+func checkCurrentSeason(name string) error {
+	if pastSeason() {
+		return fmt.Errorf("%s 只有目前球季 %s 的資料，不能用 -season %s", name, dishSeason(time.Now()), seasonOverride)
+	}
+	return nil
+}
+
+//-season 指定的日期範圍，沒有指定回傳空白
+func seasonRange() (from, to string, err error) {
+	if seasonOverride == "" {
+		return "", "", nil
+	}
+	return seasonDates(seasonOverride)
+}
+
+//date 是否在 from 到 to 之間，空白表示不限制
+func inRange(date, from, to string) bool {
+	return (from == "" || date >= from) && (to == "" || date <= to)
+}
+
+//命令列上有沒有給某個 flag
+func flagSet(fs *flag.FlagSet, name string) (result bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			result = true
+		}
+	})
+	return result
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//scanNBA serve [-addr :8080] [-n 5] [-skip-if-necessary] [-season 2022-23]
+//
+//	GET /games?date=yyyy-mm-dd       當天賽程
+//	GET /games/{id}                  單場比賽，今天找不到會去資料庫找
+//	GET /teams/{abbr}/injuries       某隊傷兵，eg: /teams/LAL/injuries
+//	GET /teams/{abbr}/ats?n=10       某隊近期過盤和最近 n 場的讓分統計，type=playoffs 看季後賽
+//	GET /report?format=json|text&n=  和 PKTeam 一樣的報告
+//
+//資料和 PKTeam 共用同一份快取，資料庫在 serve 期間一直開著(其他指令要等 serve 結束才能用)，
+//收到 SIGINT/SIGTERM 會等進行中的 request 結束再關閉
+func serveCmd(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "監聽的位址")
+	fs.IntVar(&atsLookback, "n", atsLookback, "讓分和大小分看最近幾場")
+	fs.BoolVar(&skipIfNecessary, "skip-if-necessary", skipIfNecessary, "略過季後賽「如果需要」才打的比賽")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	//serve 依每天的賽程判斷球季，指定別季會把別季的過盤套到今天的比賽
+	if err := checkCurrentSeason("serve"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	//handler 會同時讀寫資料庫，整個 serve 期間只開一次
+	store, err := openStore(storePath())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer store.Close()
+	sharedStore = store
+	defer func() { sharedStore = nil }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/games", handleGames)
+	mux.HandleFunc("/games/", handleGame)
+	mux.HandleFunc("/teams/", handleTeam)
+	mux.HandleFunc("/report", handleReport)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           onlyGet(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Println("關閉 server 失敗:", err)
+		}
+	}()
+
+	fmt.Println("serve on", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Println(err)
+		return
+	}
+	<-done
+	fmt.Println("server 已關閉")
+}
+
+func onlyGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			httpError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//回傳內容，ETag 相同時回 304
+func writeBody(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	sum := sha1.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, v := range strings.Split(match, ",") {
+			if v = strings.TrimSpace(v); v == etag || v == "W/"+etag || v == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeBody(w, r, "application/json; charset=utf-8", body)
+}
+
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+//?date=yyyy-mm-dd，沒給就是今天
+func queryDate(r *http.Request) (string, error) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		return gameDate(time.Now()), nil
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", fmt.Errorf("date 格式錯誤，eg: 2023-02-20")
+	}
+	return date, nil
+}
+
+func handleGames(w http.ResponseWriter, r *http.Request) {
+	date, err := queryDate(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	schedule, err := loadSchedule(date)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	games := schedule.Payload.Date.Games
+	if games == nil {
+		games = []Game{}
+	}
+	writeJSON(w, r, map[string]interface{}{"date": date, "games": games})
+}
+
+func handleGame(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/games/")
+	if id == "" || strings.Contains(id, "/") {
+		httpError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	date, err := queryDate(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if schedule, err := loadSchedule(date); err == nil {
+		for _, v := range schedule.Payload.Date.Games {
+			if v.Profile.GameID == id {
+				writeJSON(w, r, map[string]interface{}{"date": date, "game": v})
+				return
+			}
+		}
+	}
+
+	var (
+		game Game
+		ok   bool
+	)
+	err = withStore(func(s *Store) (err error) {
+		game, date, ok, err = s.Game(id)
+		return err
+	})
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		httpError(w, http.StatusNotFound, "找不到比賽 "+id)
+		return
+	}
+	writeJSON(w, r, map[string]interface{}{"date": date, "game": game})
+}
+
+//?n=10，沒給就是 atsLookback
+func queryLookback(r *http.Request) (int, error) {
+	n := r.URL.Query().Get("n")
+	if n == "" {
+		return atsLookback, nil
+	}
+	v, err := strconv.Atoi(n)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("n 必須是正整數")
+	}
+	return v, nil
+}
+
+//?type=playoffs 看季後賽(含附加賽)，沒給就是例行賽
+func queryKind(r *http.Request) (int, error) {
+	switch r.URL.Query().Get("type") {
+	case "", "regular":
+		return titanRegular, nil
+	case "playoffs":
+		return titanPlayoffs, nil
+	}
+	return 0, fmt.Errorf("type 必須是 regular 或 playoffs")
+}
+
+//teams/{abbr}/injuries、teams/{abbr}/ats
+func handleTeam(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/teams/"), "/")
+	if len(parts) != 2 {
+		httpError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	abbr := strings.ToUpper(parts[0])
+	team, ok := TeamAbbrInit()[abbr]
+	if !ok {
+		httpError(w, http.StatusNotFound, "找不到隊伍 "+parts[0])
+		return
+	}
+
+	switch parts[1] {
+	case "injuries":
+		injuries, err := loadInjuries()
+		if err != nil {
+			httpError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		teamInjury := teamInjuries(injuries, team)
+		if teamInjury == nil {
+			teamInjury = []Injury{}
+		}
+		writeJSON(w, r, map[string]interface{}{
+			"team":     team,
+			"injuries": teamInjury,
+			"comment":  injuryComment(TeamInit(), team, teamInjury),
+		})
+	case "ats":
+		lookback, err := queryLookback(r)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		kind, err := queryKind(r)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		dish, err := dishResults(seasonOf(time.Now()), kind, team, lookback)
+		if err != nil {
+			httpError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		matches, err := loadKindMatches(seasonOf(time.Now()), kind)
+		if err != nil {
+			httpError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		ats := teamATS(matches, team, lookback)
+		//bs1.js 只有例行賽
+		var records map[string][]string
+		if kind == titanRegular {
+			if records, err = loadTotalsRecords(seasonOf(time.Now())); err != nil {
+				fmt.Println("titan007 大小分資料錯誤:", err)
+			}
+		}
+		writeJSON(w, r, map[string]interface{}{
+			"team":    team,
+			"results": dish,
+			"dish":    dishText(dish),
+			"ats":     ats,
+			"text":    ats.Text(),
+			"totals":  teamTotalsSummary(matches, records, team, lookback),
+		})
+	default:
+		httpError(w, http.StatusNotFound, "not found")
+	}
+}
+
+//report?format=json|text&date=
+func handleReport(w http.ResponseWriter, r *http.Request) {
+	date, err := queryDate(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lookback, err := queryLookback(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	schedule, err := loadSchedule(date)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	report, err := buildReport(date, schedule, lookback)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		writeJSON(w, r, report)
+	case "text":
+		if len(report.Games) == 0 {
+			writeBody(w, r, "text/plain; charset=utf-8", []byte("今天 "+date+" 沒有比賽 \n"))
+			return
+		}
+		commentMsg, msg := consoleText(&report)
+		writeBody(w, r, "text/plain; charset=utf-8", []byte(commentMsg+"\n"+msg))
+	default:
+		httpError(w, http.StatusBadRequest, "不支援的 format: "+format)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+//讓分的結算結果
+const (
+	ATSCover = "cover"
+	ATSPush  = "push"
+	ATSFail  = "fail"
+
+	ATSUnknown = "unknown" //來源的結果看不懂
+)
+
+//賽前記錄的讓分，比賽結束後用 daily.json 的比分結算
+type Spread struct {
+	GameID     string    `json:"gameId"`
+	Date       string    `json:"date"`
+	AwayTeam   string    `json:"awayTeam"`
+	HomeTeam   string    `json:"homeTeam"`
+	Line       float64   `json:"line"`   //主隊的讓分，-5.5 表示主隊讓 5.5 分
+	Source     string    `json:"source"` //titan007 或 manual
+	RecordedAt time.Time `json:"recordedAt"`
+
+	Settled    bool   `json:"settled"`
+	AwayScore  int    `json:"awayScore,omitempty"`
+	HomeScore  int    `json:"homeScore,omitempty"`
+	HomeResult string `json:"homeResult,omitempty"`
+	AwayResult string `json:"awayResult,omitempty"`
+}
+
+//用主隊讓分結算，回傳主客隊的結果
+func settleSpread(line float64, homeScore, awayScore int) (home, away string) {
+	switch margin := float64(homeScore-awayScore) + line; {
+	case margin > 0:
+		return ATSCover, ATSFail
+	case margin < 0:
+		return ATSFail, ATSCover
+	default:
+		return ATSPush, ATSPush
+	}
+}
+
+//結算結果的中文
+func atsLabel(result string) string {
+	switch result {
+	case ATSCover:
+		return "贏"
+	case ATSPush:
+		return "走"
+	case ATSFail:
+		return "輸"
+	}
+	return "-"
+}
+
+//scanNBA spread record|set|settle|check|list
+func spreadCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("用法: scanNBA spread record|set|settle|check|list")
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "record":
+		err = spreadRecord(args[1:])
+	case "set":
+		err = spreadSet(args[1:])
+	case "settle":
+		err = spreadSettle(args[1:])
+	case "check":
+		err = spreadCheck(args[1:])
+	case "list":
+		err = spreadList(args[1:])
+	default:
+		fmt.Println("未知的指令: spread " + args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func newSpread(date string, g Game, line float64, source string) Spread {
+	return Spread{
+		GameID:     g.Profile.GameID,
+		Date:       date,
+		AwayTeam:   g.AwayTeam.FullName(),
+		HomeTeam:   g.HomeTeam.FullName(),
+		Line:       line,
+		Source:     source,
+		RecordedAt: time.Now(),
+	}
+}
+
+//scanNBA spread record [-date yyyy-mm-dd] [-season 2022-23]
+//
+//從 titan007 記錄還沒開打的比賽讓分，手動輸入過的不會被蓋掉
+func spreadRecord(args []string) error {
+	fs := flag.NewFlagSet("spread record", flag.ExitOnError)
+	date := fs.String("date", gameDate(time.Now()), "比賽日期 yyyy-mm-dd")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	day, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := loadSchedule(*date)
+	if err != nil {
+		return err
+	}
+
+	//依比賽種類讀例行賽或季後賽的檔案，季前賽和明星賽沒有盤口
+	kinds := make(map[int]bool)
+	for _, g := range schedule.Payload.Date.Games {
+		if kind, ok := titanKind(g.SeasonType()); ok && !g.SummerLeague() {
+			kinds[kind] = true
+		}
+	}
+
+	//美東晚上的比賽在北京時間是隔天，可能跨月
+	var matches []TitanMatch
+	season := seasonOf(day)
+	for kind := range kinds {
+		for _, month := range []time.Time{day, day.AddDate(0, 0, 1)} {
+			m, err := loadTitanKindMatches(season, kind, month)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, m...)
+			if day.Month() == day.AddDate(0, 0, 1).Month() {
+				break
+			}
+		}
+	}
+
+	return withStore(func(s *Store) error {
+		for _, g := range schedule.Payload.Date.Games {
+			if g.Boxscore.Status != StatusScheduled {
+				continue
+			}
+			if old, ok, err := s.Spread(g.Profile.GameID); err != nil {
+				return err
+			} else if ok && old.Source == "manual" {
+				continue
+			}
+
+			m, ok := findTitanMatch(matches, g)
+			if !ok || !m.HasLine {
+				fmt.Println(g.AwayTeam.FullName() + " @ " + g.HomeTeam.FullName() + " 找不到 titan007 的讓分")
+				continue
+			}
+
+			spread := newSpread(*date, g, -m.Line, "titan007")
+			if err := s.SaveSpread(spread); err != nil {
+				return err
+			}
+			fmt.Printf("%s @ %s  主隊 %+.1f\n", spread.AwayTeam, spread.HomeTeam, spread.Line)
+		}
+		return nil
+	})
+}
+
+//scanNBA spread set -game 0022200123 -line -5.5 [-date yyyy-mm-dd] [-season 2022-23]
+//
+//手動輸入主隊讓分
+func spreadSet(args []string) error {
+	fs := flag.NewFlagSet("spread set", flag.ExitOnError)
+	date := fs.String("date", gameDate(time.Now()), "比賽日期 yyyy-mm-dd")
+	gameID := fs.String("game", "", "nba.com 的 gameId")
+	line := fs.String("line", "", "主隊讓分，eg: -5.5")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	if err := checkSeasonDate(*date); err != nil {
+		return err
+	}
+
+	value, err := strconv.ParseFloat(*line, 64)
+	if err != nil {
+		return fmt.Errorf("讓分格式錯誤: %v", err)
+	}
+
+	schedule, err := loadSchedule(*date)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range schedule.Payload.Date.Games {
+		if g.Profile.GameID != *gameID {
+			continue
+		}
+		spread := newSpread(*date, g, value, "manual")
+		return withStore(func(s *Store) error {
+			return s.SaveSpread(spread)
+		})
+	}
+	return fmt.Errorf("%s 找不到比賽 %s", *date, *gameID)
+}
+
+//scanNBA spread settle [-season 2022-23]
+//
+//用 daily.json 的最終比分結算所有還沒結算的讓分，某天的賽程抓不到就印出來，繼續結算其他天
+func spreadSettle(args []string) error {
+	fs := flag.NewFlagSet("spread settle", flag.ExitOnError)
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	from, to, err := seasonRange()
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(s *Store) error {
+		spreads, err := s.Spreads()
+		if err != nil {
+			return err
+		}
+
+		//每天的賽程只抓一次，抓不到就跳過那天的讓分
+		fetched := make(map[string]bool)
+		failed := make(map[string]bool)
+		for _, spread := range spreads {
+			if spread.Settled || failed[spread.Date] || !inRange(spread.Date, from, to) {
+				continue
+			}
+
+			if !fetched[spread.Date] {
+				fetched[spread.Date] = true
+				schedule, err := loadSchedule(spread.Date)
+				if err != nil {
+					fmt.Println(spread.Date, "讀取賽程失敗:", err)
+					failed[spread.Date] = true
+					continue
+				}
+				if err := s.SaveSchedule(spread.Date, schedule); err != nil {
+					return err
+				}
+			}
+
+			score, ok, err := s.Score(spread.GameID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			spread.Settled = true
+			spread.AwayScore = score.AwayScore
+			spread.HomeScore = score.HomeScore
+			spread.HomeResult, spread.AwayResult = settleSpread(spread.Line, score.HomeScore, score.AwayScore)
+			if err := s.SaveSpread(spread); err != nil {
+				return err
+			}
+			fmt.Println(spreadText(spread))
+		}
+		return nil
+	})
+}
+
+//scanNBA spread check [-season 2022-23]
+//
+//用 titan007 matchResult 的盤口和比分重算每場已結算的讓分，列出和自己結算結果不同的比賽
+func spreadCheck(args []string) error {
+	fs := flag.NewFlagSet("spread check", flag.ExitOnError)
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	from, to, err := seasonRange()
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(s *Store) error {
+		spreads, err := s.Spreads()
+		if err != nil {
+			return err
+		}
+
+		//每季每種比賽的 matchResult 只讀一次
+		loaded := make(map[string][]TitanMatch)
+		var checked, differ, missing int
+		for _, spread := range spreads {
+			if !spread.Settled || !inRange(spread.Date, from, to) {
+				continue
+			}
+			g, _, ok, err := s.Game(spread.GameID)
+			if err != nil {
+				return err
+			}
+			kind, hasKind := titanKind(g.SeasonType())
+			day, dateErr := time.Parse("2006-01-02", spread.Date)
+			if !ok || !hasKind || g.SummerLeague() || dateErr != nil {
+				missing++
+				continue
+			}
+
+			season := seasonOf(day)
+			key := season + "/" + strconv.Itoa(kind)
+			matches, ok := loaded[key]
+			if !ok {
+				matches, err = loadKindMatches(season, kind)
+				if err != nil {
+					fmt.Println(season, "titan007 比賽資料錯誤:", err)
+				}
+				loaded[key] = matches
+			}
+
+			m, ok := findTitanMatch(matches, g)
+			if !ok || !m.Finished || !m.HasLine {
+				missing++
+				continue
+			}
+
+			checked++
+			home, away := settleSpread(-m.Line, m.HomeScore, m.AwayScore)
+			if home == spread.HomeResult && away == spread.AwayResult {
+				continue
+			}
+			differ++
+			fmt.Println(spreadText(spread))
+			fmt.Printf("  titan007 主隊 %+.1f  %d:%d  客%s 主%s\n", -m.Line, m.AwayScore, m.HomeScore, atsLabel(away), atsLabel(home))
+		}
+
+		fmt.Printf("比對 %d 場，結果不同 %d 場，titan007 沒有盤口或比分 %d 場\n", checked, differ, missing)
+		return nil
+	})
+}
+
+//scanNBA spread list
+func spreadList(args []string) error {
+	fs := flag.NewFlagSet("spread list", flag.ExitOnError)
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	from, to, err := seasonRange()
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(s *Store) error {
+		spreads, err := s.Spreads()
+		if err != nil {
+			return err
+		}
+		for _, spread := range spreads {
+			if !inRange(spread.Date, from, to) {
+				continue
+			}
+			fmt.Println(spreadText(spread))
+		}
+		return nil
+	})
+}
+
+//eg: "2023-02-20 Los Angeles Lakers @ Golden State Warriors 主隊 -5.5 (titan007)  110:105  客贏 主輸"
+func spreadText(spread Spread) string {
+	msg := fmt.Sprintf("%s %s @ %s 主隊 %+.1f (%s)", spread.Date, spread.AwayTeam, spread.HomeTeam, spread.Line, spread.Source)
+	if !spread.Settled {
+		return msg + "  未結算"
+	}
+	return msg + fmt.Sprintf("  %d:%d  客%s 主%s", spread.AwayScore, spread.HomeScore, atsLabel(spread.AwayResult), atsLabel(spread.HomeResult))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//隊伍的戰績和排名，來自 daily.json 的 Matchup
+type TeamRecord struct {
+	Team       string `json:"team"`
+	Conference string `json:"conference"`
+	Division   string `json:"division"`
+	Wins       int    `json:"wins"`
+	Losses     int    `json:"losses"`
+	ConfRank   string `json:"confRank"`
+	DivRank    string `json:"divRank"`
+	Date       string `json:"date"` //這份戰績的比賽日期
+}
+
+func teamRecord(date string, t GameTeam) TeamRecord {
+	wins, _ := strconv.Atoi(t.Matchup.Wins)
+	losses, _ := strconv.Atoi(t.Matchup.Losses)
+	return TeamRecord{
+		Team:       t.FullName(),
+		Conference: t.Profile.Conference,
+		Division:   t.Profile.Division,
+		Wins:       wins,
+		Losses:     losses,
+		ConfRank:   t.Matchup.ConfRank,
+		DivRank:    t.Matchup.DivRank,
+		Date:       date,
+	}
+}
+
+func (r TeamRecord) WinPct() float64 {
+	if r.Wins+r.Losses == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Wins+r.Losses)
+}
+
+//eg: "30-20 西區第3 分區第2"
+func (r TeamRecord) Text() string {
+	if r.Wins+r.Losses == 0 && r.ConfRank == "" {
+		return "無戰績"
+	}
+	msg := fmt.Sprintf("%d-%d", r.Wins, r.Losses)
+	if r.ConfRank != "" {
+		msg = msg + " " + conferenceLabel(r.Conference) + "第" + r.ConfRank
+	}
+	if r.DivRank != "" {
+		msg = msg + " 分區第" + r.DivRank
+	}
+	return msg
+}
+
+func conferenceLabel(conference string) string {
+	switch conference {
+	case "Eastern":
+		return "東區"
+	case "Western":
+		return "西區"
+	}
+	return conference
+}
+
+//和 leader 的勝差
+func gamesBehind(leader, r TeamRecord) float64 {
+	return float64((leader.Wins-r.Wins)+(r.Losses-leader.Losses)) / 2
+}
+
+//每隊最新的一份戰績
+func latestRecords(s *Store, from, to string) (map[string]TeamRecord, error) {
+	result := make(map[string]TeamRecord)
+	err := s.EachGame(func(date string, g Game) error {
+		//只算例行賽，季後賽的 Matchup 是系列賽戰績，季前賽和夏季聯賽是另外的戰績
+		if !inRange(date, from, to) || g.SeasonType() != SeasonRegular || g.SummerLeague() {
+			return nil
+		}
+		for _, t := range []GameTeam{g.AwayTeam, g.HomeTeam} {
+			//非正規球隊(明星賽等)不列入
+			if !t.Profile.IsLeagueTeam || t.Profile.IsAllStarTeam {
+				continue
+			}
+			if old, ok := result[t.FullName()]; !ok || old.Date <= date {
+				result[t.FullName()] = teamRecord(date, t)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+//依勝率排序
+func sortRecords(records []TeamRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].WinPct() != records[j].WinPct() {
+			return records[i].WinPct() > records[j].WinPct()
+		}
+		return records[i].Wins > records[j].Wins
+	})
+}
+
+func standingsTable(title string, records []TeamRecord, teamMap map[string]string) (msg string) {
+	sortRecords(records)
+
+	msg = title + "\n"
+	msg = msg + fmt.Sprintf(" %2s  %-6s %4s %4s %6s %6s\n", "#", "隊伍", "勝", "敗", "勝率", "勝差")
+	for i, r := range records {
+		gb := "-"
+		if i > 0 {
+			gb = strconv.FormatFloat(gamesBehind(records[0], r), 'f', 1, 64)
+		}
+		msg = msg + fmt.Sprintf(" %2d  %-6s %4d %4d %6.3f %6s\n", i+1, chineseName(teamMap, r.Team), r.Wins, r.Losses, r.WinPct(), gb)
+	}
+	return msg + "\n"
+}
+
+//scanNBA standings [-division] [-season 2022-23]
+//
+//用資料庫內每隊這一季(或 -season 那一季)最新的戰績排出東西區和分區排名，會先存下今天的賽程
+func standingsCmd(args []string) {
+	fs := flag.NewFlagSet("standings", flag.ExitOnError)
+	division := fs.Bool("division", false, "也印出分區排名")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	//沒有 -season 時看目前這一季，新球季還沒打的隊伍才不會顯示上一季的戰績
+	from, to, err := seasonDates(seasonOf(time.Now()))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	//過去的球季只看資料庫
+	if date := gameDate(time.Now()); inRange(date, from, to) {
+		if schedule, err := loadSchedule(date); err == nil {
+			saveToStore(func(s *Store) error {
+				return s.SaveSchedule(date, schedule)
+			})
+		} else {
+			fmt.Println(err)
+		}
+	}
+
+	var records map[string]TeamRecord
+	if err := withStore(func(s *Store) (err error) {
+		records, err = latestRecords(s, from, to)
+		return err
+	}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("資料庫沒有戰績，請先執行 backfill")
+		return
+	}
+
+	conferences := make(map[string][]TeamRecord)
+	divisions := make(map[string][]TeamRecord)
+	for _, r := range records {
+		conferences[r.Conference] = append(conferences[r.Conference], r)
+		divisions[r.Division] = append(divisions[r.Division], r)
+	}
+
+	teamMap := TeamInit()
+	var msg string
+	for _, c := range []string{"Eastern", "Western"} {
+		msg = msg + standingsTable(conferenceLabel(c), conferences[c], teamMap)
+	}
+
+	if *division {
+		var names []string
+		for name := range divisions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			msg = msg + standingsTable(name, divisions[name], teamMap)
+		}
+	}
+
+	fmt.Print(msg)
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//本地資料庫，存每次抓到的賽程、比分、傷兵和過盤資料
+type Store struct {
+	db *bolt.DB
+}
+
+var (
+	bucketMeta     = []byte("meta")
+	bucketGames    = []byte("games")    //日期/gameId -> Game
+	bucketScores   = []byte("scores")   //gameId -> FinalScore
+	bucketInjuries = []byte("injuries") //快照時間 -> InjurySnapshot
+	bucketATS      = []byte("ats")      //日期/隊伍 -> ATSRecord
+	bucketSpreads  = []byte("spreads")  //gameId -> Spread
+	bucketImpact   = []byte("impact")   //球員編號 -> PlayerImpact
+	bucketPredict  = []byte("predict")  //日期/gameId/模型 -> Prediction
+	bucketBets     = []byte("bets")     //流水號 -> Bet
+	bucketPlayers  = []byte("players")  //球員編號 -> Player
+	bucketGameDate = []byte("dates")    //gameId -> 日期，用 gameId 找比賽時不用掃過所有日期
+
+	keySchemaVersion = []byte("schema_version")
+)
+
+//schema 的版本依序升級，已經跑過的不會再跑，新的 migration 只能加在最後面
+var migrations = []func(tx *bolt.Tx) error{
+	//1: 建立基本的 bucket
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketGames, bucketScores, bucketInjuries, bucketATS} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	//2: 自己記錄的讓分和結算結果
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketSpreads)
+		return err
+	},
+	//3: 球員影響力
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketImpact)
+		return err
+	},
+	//4: 模型的預測
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketPredict)
+		return err
+	},
+	//5: 下注紀錄
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketBets)
+		return err
+	},
+	//6: 球員編號，影響力的 key 從球員名稱改成編號
+	//
+	//舊資料庫的升級結果不能跟著現在的球員比對改變，這裡不用 PlayerRegistry，
+	//每個名稱直接登記成一個球員，之後的寫法差異在讀取時比對
+	func(tx *bolt.Tx) error {
+		players, err := tx.CreateBucketIfNotExists(bucketPlayers)
+		if err != nil {
+			return err
+		}
+
+		var impacts []map[string]interface{}
+		b := tx.Bucket(bucketImpact)
+		if err := b.ForEach(func(k, v []byte) error {
+			var impact map[string]interface{}
+			if err := json.Unmarshal(v, &impact); err != nil {
+				return err
+			}
+			impacts = append(impacts, impact)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket(bucketImpact); err != nil {
+			return err
+		}
+		b, err = tx.CreateBucket(bucketImpact)
+		if err != nil {
+			return err
+		}
+
+		type player struct {
+			ID        string    `json:"id"`
+			Name      string    `json:"name"`
+			Team      string    `json:"team,omitempty"`
+			CreatedAt time.Time `json:"createdAt"`
+		}
+		ids := make(map[string]string)
+		for _, impact := range impacts {
+			name, _ := impact["player"].(string)
+			team, _ := impact["team"].(string)
+			key := strings.ToLower(strings.TrimSpace(name))
+			id, ok := ids[key]
+			if !ok {
+				id = fmt.Sprintf("P%05d", len(ids)+1)
+				ids[key] = id
+				if err := putJSON(players, id, player{ID: id, Name: strings.TrimSpace(name), Team: team, CreatedAt: time.Now()}); err != nil {
+					return err
+				}
+			}
+			impact["playerId"] = id
+			if err := putJSON(b, id, impact); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	//7: gameId 對應比賽日期的索引
+	func(tx *bolt.Tx) error {
+		index, err := tx.CreateBucketIfNotExists(bucketGameDate)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketGames).ForEach(func(k, v []byte) error {
+			i := bytes.IndexByte(k, '/')
+			if i < 0 {
+				return nil
+			}
+			return index.Put(k[i+1:], k[:i])
+		})
+	},
+}
+
+//比賽結束後的比分
+type FinalScore struct {
+	GameID    string `json:"gameId"`
+	Date      string `json:"date"`
+	AwayTeam  string `json:"awayTeam"`
+	HomeTeam  string `json:"homeTeam"`
+	AwayScore int    `json:"awayScore"`
+	HomeScore int    `json:"homeScore"`
+}
+
+//titan007 上某隊某天的近期過盤
+type ATSRecord struct {
+	Date    string `json:"date"`
+	Team    string `json:"team"`
+	Results string `json:"results"`
+}
+
+//資料庫檔案的位置，可以用 SCANNBA_DB 改
+func storePath() string {
+	if path := os.Getenv("SCANNBA_DB"); path != "" {
+		return path
+	}
+	return "scanNBA.db"
+}
+
+//開啟資料庫並跑完 migration
+func openStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+//常駐的 serve 整個執行期間共用的資料庫，同一個 process 重複開啟會被檔案鎖卡住
+var sharedStore *Store
+
+//開啟資料庫執行 f，用完就關掉，避免長時間鎖住檔案；有 sharedStore 就直接用
+func withStore(f func(s *Store) error) error {
+	if sharedStore != nil {
+		return f(sharedStore)
+	}
+
+	s, err := openStore(storePath())
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return f(s)
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+
+		var version uint64
+		if v := meta.Get(keySchemaVersion); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+		if version > uint64(len(migrations)) {
+			return fmt.Errorf("資料庫版本 %d 比程式支援的 %d 新", version, len(migrations))
+		}
+
+		for ; version < uint64(len(migrations)); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("migration %d: %v", version+1, err)
+			}
+		}
+
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, version)
+		return meta.Put(keySchemaVersion, v)
+	})
+}
+
+func putJSON(b *bolt.Bucket, key string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), body)
+}
+
+//存下某天的賽程，已經結束的比賽另外存比分
+func (s *Store) SaveSchedule(date string, schedule Schedule) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		games := tx.Bucket(bucketGames)
+		scores := tx.Bucket(bucketScores)
+		index := tx.Bucket(bucketGameDate)
+
+		for _, v := range schedule.Payload.Date.Games {
+			if err := putJSON(games, date+"/"+v.Profile.GameID, v); err != nil {
+				return err
+			}
+			if err := index.Put([]byte(v.Profile.GameID), []byte(date)); err != nil {
+				return err
+			}
+
+			if !v.Final() {
+				continue
+			}
+			score := FinalScore{
+				GameID:    v.Profile.GameID,
+				Date:      date,
+				AwayTeam:  v.AwayTeam.FullName(),
+				HomeTeam:  v.HomeTeam.FullName(),
+				AwayScore: v.Boxscore.AwayScore,
+				HomeScore: v.Boxscore.HomeScore,
+			}
+			if err := putJSON(scores, v.Profile.GameID, score); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//某天存下來的比賽
+func (s *Store) Games(date string) (result []Game, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketGames).Cursor()
+		prefix := []byte(date + "/")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var game Game
+			if err := json.Unmarshal(v, &game); err != nil {
+				return err
+			}
+			result = append(result, game)
+		}
+		return nil
+	})
+	return result, err
+}
+
+//某場比賽的最終比分，還沒結束或沒存過回傳 false
+func (s *Store) Score(gameID string) (score FinalScore, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketScores).Get([]byte(gameID))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(v, &score)
+	})
+	return score, ok, err
+}
+
+func (s *Store) SaveInjurySnapshot(snapshot InjurySnapshot) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketInjuries), snapshot.Time.UTC().Format(time.RFC3339Nano), snapshot)
+	})
+}
+
+func (s *Store) SaveATS(record ATSRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketATS), record.Date+"/"+record.Team, record)
+	})
+}
+
+//寫進資料庫失敗只印出錯誤，不影響原本的輸出
+func saveToStore(f func(s *Store) error) {
+	if err := withStore(f); err != nil {
+		fmt.Println("寫入資料庫失敗:", err)
+	}
+}
+
+//用 gameId 找存過的比賽，回傳比賽日期
+func (s *Store) Game(gameID string) (game Game, date string, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		d := tx.Bucket(bucketGameDate).Get([]byte(gameID))
+		if d == nil {
+			return nil
+		}
+		v := tx.Bucket(bucketGames).Get([]byte(string(d) + "/" + gameID))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		date = string(d)
+		return json.Unmarshal(v, &game)
+	})
+	return game, date, ok, err
+}
+
+func (s *Store) SaveSpread(spread Spread) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketSpreads), spread.GameID, spread)
+	})
+}
+
+func (s *Store) Spread(gameID string) (spread Spread, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketSpreads).Get([]byte(gameID))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(v, &spread)
+	})
+	return spread, ok, err
+}
+
+//所有記錄過的讓分，依比賽日期排序
+func (s *Store) Spreads() (result []Spread, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSpreads).ForEach(func(k, v []byte) error {
+			var spread Spread
+			if err := json.Unmarshal(v, &spread); err != nil {
+				return err
+			}
+			result = append(result, spread)
+			return nil
+		})
+	})
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+	return result, err
+}
+
+//所有存過的最終比分
+func (s *Store) Scores() (result []FinalScore, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketScores).ForEach(func(k, v []byte) error {
+			var score FinalScore
+			if err := json.Unmarshal(v, &score); err != nil {
+				return err
+			}
+			result = append(result, score)
+			return nil
+		})
+	})
+	return result, err
+}
+
+//依日期順序走過所有存過的比賽
+func (s *Store) EachGame(f func(date string, g Game) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketGames).ForEach(func(k, v []byte) error {
+			var game Game
+			if err := json.Unmarshal(v, &game); err != nil {
+				return err
+			}
+			date := string(k[:bytes.IndexByte(k, '/')])
+			return f(date, game)
+		})
+	})
+}
+
+func (s *Store) SaveImpacts(impacts []PlayerImpact) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putImpacts(tx, impacts)
+	})
+}
+
+//影響力用球員編號存，新的球員順便登記
+func putImpacts(tx *bolt.Tx, impacts []PlayerImpact) error {
+	r, err := loadPlayerRegistry(tx)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(bucketImpact)
+	for _, v := range impacts {
+		v.PlayerID = r.Register(v.Player, v.Team)
+		if err := putJSON(b, v.PlayerID, v); err != nil {
+			return err
+		}
+	}
+	return r.save(tx)
+}
+
+//所有球員的影響力，key 是球員編號
+func (s *Store) Impacts() (map[string]PlayerImpact, error) {
+	result := make(map[string]PlayerImpact)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketImpact).ForEach(func(k, v []byte) error {
+			var impact PlayerImpact
+			if err := json.Unmarshal(v, &impact); err != nil {
+				return err
+			}
+			result[string(k)] = impact
+			return nil
+		})
+	})
+	return result, err
+}
+
+//同一場同一個模型只留最後一次預測
+func (s *Store) SavePrediction(p Prediction) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketPredict), p.Date+"/"+p.GameID+"/"+p.Model, p)
+	})
+}
+
+//所有預測，依日期排序
+func (s *Store) Predictions() (result []Prediction, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPredict).ForEach(func(k, v []byte) error {
+			var p Prediction
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			result = append(result, p)
+			return nil
+		})
+	})
+	return result, err
+}
+
+//新的下注會分配流水號，key 補零讓 ForEach 照順序
+func (s *Store) SaveBet(bet *Bet) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBets)
+		if bet.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			bet.ID = id
+		}
+		return putJSON(b, fmt.Sprintf("%010d", bet.ID), bet)
+	})
+}
+
+//所有下注，依流水號排序
+func (s *Store) Bets() (result []Bet, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketBets).ForEach(func(k, v []byte) error {
+			var bet Bet
+			if err := json.Unmarshal(v, &bet); err != nil {
+				return err
+			}
+			result = append(result, bet)
+			return nil
+		})
+	})
+	return result, err
+}
+
+//所有登記的球員
+func (s *Store) Players() (r *PlayerRegistry, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		r, err = loadPlayerRegistry(tx)
+		return err
+	})
+	return r, err
+}
+
+//在同一個 transaction 內讀出球員、交給 f 修改再寫回，f 回傳錯誤就不寫
+func (s *Store) UpdatePlayers(f func(r *PlayerRegistry) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		r, err := loadPlayerRegistry(tx)
+		if err != nil {
+			return err
+		}
+		if err := f(r); err != nil {
+			return err
+		}
+		return r.save(tx)
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example/scanNBA/odds"
+)
+
+//titan007 的每場比賽，時間是北京時間
+type TitanMatch struct {
+	ID        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	Home      string    `json:"home"`
+	Away      string    `json:"away"`
+	Finished  bool      `json:"finished"`
+	HomeScore int       `json:"homeScore"`
+	AwayScore int       `json:"awayScore"`
+	Line      float64   `json:"line"` //titan 的讓分，正數是主隊讓分
+	HasLine   bool      `json:"hasLine"`
+	Total     float64   `json:"total"` //大小分盤口
+	HasTotal  bool      `json:"hasTotal"`
+}
+
+//matchResult 每一場比賽陣列的欄位位置
+const (
+	titanFieldID     = 0
+	titanFieldState  = 2 //-1 是已結束
+	titanFieldTime   = 3 //"2023-02-21 08:00"
+	titanFieldHomeID = 4
+	titanFieldAwayID = 5
+	titanFieldScore  = 6 //"110-105"，主隊在前
+	titanFieldLine   = 10
+	titanFieldTotal  = 12 //依位置推測的，用 validTotalLine 和 bs1.js 的結果驗證
+)
+
+var (
+	beijing     = time.FixedZone("UTC+8", 8*60*60)
+	arrayRegexp = regexp.MustCompile(`\[([^\[\]]*)\]`)
+)
+
+//把 js 的一維陣列內容拆成欄位，會去掉字串的引號
+func splitJSArray(s string) (result []string) {
+	var (
+		field strings.Builder
+		quote rune
+	)
+	for _, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			result = append(result, strings.TrimSpace(field.String()))
+			field.Reset()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	return append(result, strings.TrimSpace(field.String()))
+}
+
+//取出 js 內所有的一維陣列
+func jsArrays(s string) (result [][]string) {
+	for _, m := range arrayRegexp.FindAllStringSubmatch(s, -1) {
+		result = append(result, splitJSArray(m[1]))
+	}
+	return result
+}
+
+//titan007 的隊伍 id 對應英文隊名，從 l1.js 的 arrTeam 取得
+func titanTeams(season string) (map[int]string, error) {
+	sitemap, err := loadDishData(season)
+	if err != nil {
+		return nil, err
+	}
+
+	body := string(sitemap)
+	start := strings.Index(body, "arrTeam")
+	if start < 0 {
+		return nil, fmt.Errorf("l1.js 找不到 arrTeam")
+	}
+	end := strings.Index(body[start:], ";")
+	if end < 0 {
+		end = len(body) - start
+	}
+
+	result := make(map[int]string)
+	for _, v := range jsArrays(body[start : start+end]) {
+		if len(v) < 4 {
+			continue
+		}
+		id, err := strconv.Atoi(v[0])
+		if err != nil {
+			continue
+		}
+		result[id] = v[3]
+	}
+	return result, nil
+}
+
+//matchResult 檔名 l1_{kind}_{年}_{月}.js 的 kind
+const (
+	titanRegular  = 1
+	titanPlayoffs = 2
+)
+
+//下載某個月的比賽結果
+func fetchTitanMatches(season string, kind int, month time.Time) ([]byte, error) {
+	url := "https://nba.titan007.com/jsData/matchResult/" + season + "/l1_" + strconv.Itoa(kind) + "_" + month.Format("2006") + "_" + strconv.Itoa(int(month.Month())) + ".js?version=" + time.Now().Format("2006010215")
+
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+//titan 的盤口，可能是 "5.5"、"5/5.5" 或 "-0/0.5"
+func parseTitanLine(s string) (float64, bool) {
+	v, err := odds.ParseHandicap(s)
+	return v, err == nil
+}
+
+//解析 matchResult，隊名換成英文全名
+func parseTitanMatches(body []byte, teams map[int]string) (result []TitanMatch) {
+	for _, v := range jsArrays(string(body)) {
+		if len(v) <= titanFieldTotal {
+			continue
+		}
+
+		id, err := strconv.Atoi(v[titanFieldID])
+		if err != nil {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04", v[titanFieldTime], beijing)
+		if err != nil {
+			continue
+		}
+		homeID, _ := strconv.Atoi(v[titanFieldHomeID])
+		awayID, _ := strconv.Atoi(v[titanFieldAwayID])
+
+		match := TitanMatch{ID: id, Time: t, Home: teams[homeID], Away: teams[awayID]}
+		match.Line, match.HasLine = parseTitanLine(v[titanFieldLine])
+		match.Total, match.HasTotal = parseTitanLine(v[titanFieldTotal])
+		match.HasTotal = match.HasTotal && validTotalLine(match.Total)
+
+		if score := strings.Split(v[titanFieldScore], "-"); v[titanFieldState] == "-1" && len(score) == 2 {
+			match.HomeScore, _ = strconv.Atoi(score[0])
+			match.AwayScore, _ = strconv.Atoi(score[1])
+			match.Finished = true
+		}
+
+		result = append(result, match)
+	}
+	return result
+}
+
+//某個月 titan007 例行賽的所有比賽
+func loadTitanMatches(season string, month time.Time) ([]TitanMatch, error) {
+	return loadTitanKindMatches(season, titanRegular, month)
+}
+
+//某個月 titan007 某種比賽(例行賽、季後賽)的所有比賽
+func loadTitanKindMatches(season string, kind int, month time.Time) ([]TitanMatch, error) {
+	teams, err := titanTeams(season)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := dishCache.get("match/"+season+"/"+strconv.Itoa(kind)+"/"+month.Format("2006-01"), func() (interface{}, error) {
+		name := "l1_" + strconv.Itoa(kind) + "_" + month.Format("2006") + "_" + strconv.Itoa(int(month.Month())) + ".js"
+		body, err := loadSeasonFile(season, name, func() ([]byte, error) {
+			return fetchTitanMatches(season, kind, month)
+		}, validTitanMatches)
+		if err != nil {
+			return nil, err
+		}
+		return parseTitanMatches(body, teams), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]TitanMatch), nil
+}
+
+//找出 nba.com 比賽對應的 titan007 比賽，主客隊相同且開賽時間差不到 12 小時
+func findTitanMatch(matches []TitanMatch, g Game) (TitanMatch, bool) {
+	ms, _ := strconv.ParseInt(g.Profile.UtcMillis, 10, 64)
+	tip := time.Unix(0, ms*int64(time.Millisecond))
+
+	for _, m := range matches {
+		if !sameTeam(m.Home, g.HomeTeam.FullName()) || !sameTeam(m.Away, g.AwayTeam.FullName()) {
+			continue
+		}
+		diff := m.Time.Sub(tip)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < 12*time.Hour {
+			return m, true
+		}
+	}
+	return TitanMatch{}, false
+}
+
+//球季開始的時間，season 是 "22-23"，球季從十月開始，到隔年六月
+func seasonStart(season string) (time.Time, error) {
+	if len(season) < 2 {
+		return time.Time{}, fmt.Errorf("球季格式錯誤: %s", season)
+	}
+	start, err := time.Parse("06", season[:2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("球季格式錯誤: %s", season)
+	}
+	return time.Date(start.Year(), time.October, 1, 0, 0, 0, 0, beijing), nil
+}
+
+//整個球季到目前為止的比賽，依時間排序
+func loadSeasonMatches(season string) (result []TitanMatch, err error) {
+	first, err := seasonStart(season)
+	if err != nil {
+		return nil, err
+	}
+
+	for month := first; month.Before(first.AddDate(0, 9, 0)) && month.Before(time.Now()); month = month.AddDate(0, 1, 0) {
+		matches, err := loadTitanMatches(season, month)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, matches...)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+//某季某種比賽的所有比賽，季後賽(含附加賽)和例行賽是不同的 matchResult 檔案
+func loadKindMatches(season string, kind int) ([]TitanMatch, error) {
+	if kind == titanPlayoffs {
+		return loadPlayoffMatches(season)
+	}
+	return loadSeasonMatches(season)
+}
+
+//某隊最近 n 場已結束的比賽，最新的在前面
+func recentTitanMatches(matches []TitanMatch, team string, n int, keep func(m TitanMatch) bool) (result []TitanMatch) {
+	for i := len(matches) - 1; i >= 0 && len(result) < n; i-- {
+		m := matches[i]
+		if !m.Finished || (!sameTeam(m.Home, team) && !sameTeam(m.Away, team)) {
+			continue
+		}
+		if keep != nil && !keep(m) {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//大小分結果
+const (
+	TotalOver  = "over"
+	TotalUnder = "under"
+	TotalPush  = "push"
+)
+
+//某隊近期的大小分
+type TotalsSummary struct {
+	Results []string `json:"results"` //最新的在前面
+	AvgLine float64  `json:"avgLine"` //只有 bs1.js 的結果時沒有盤口，是 0
+	Source  string   `json:"source,omitempty"`
+}
+
+//大小分資料的來源
+const (
+	totalsFromMatches = "matchResult" //每場的盤口和比分
+	totalsFromRecords = "bs1.js"      //titan007 大小分頁面的每隊近期結果
+)
+
+//NBA 大小分盤口合理的範圍，matchResult 的欄位超出範圍就不是大小分
+const (
+	minTotalLine = 150
+	maxTotalLine = 300
+)
+
+func validTotalLine(v float64) bool {
+	return v >= minTotalLine && v <= maxTotalLine
+}
+
+//大小分結果的中文
+func totalLabel(result string) string {
+	switch result {
+	case TotalOver:
+		return "大"
+	case TotalUnder:
+		return "小"
+	case TotalPush:
+		return "走"
+	}
+	return "-"
+}
+
+//titan007 大小分的資料，和讓分的 letGoal/l1.js 放在同一層
+//
+// http://nba.titan007.com/cn/BigSmall.aspx?SclassID=1&matchSeason=2022-2023
+func fetchTotalsData(season string) ([]byte, error) {
+	url := "https://nba.titan007.com/jsData/bigSmall/" + season + "/bs1.js?version=" + time.Now().Format("2006010215")
+
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+func loadTotalsData(season string) ([]byte, error) {
+	v, err := dishCache.get("totals/"+season, func() (interface{}, error) {
+		return loadSeasonFile(season, "bs1.js", func() ([]byte, error) {
+			return fetchTotalsData(season)
+		}, func(body []byte) error {
+			return validTeamRows(body, totalsFieldRecent)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+//bs1.js 每隊一列，和 l1.js 一樣是 [排名, 隊伍id, ...統計..., 近期結果]
+//近期結果 "0" 大、"1" 走、"2" 小，最新的在前面
+const (
+	totalsFieldTeam   = 1
+	totalsFieldRecent = 12
+)
+
+func totalCode(s string) string {
+	switch s {
+	case "0":
+		return TotalOver
+	case "1":
+		return TotalPush
+	case "2":
+		return TotalUnder
+	}
+	return ""
+}
+
+//解析 bs1.js，回傳每隊的近期結果，key 是英文隊名
+func parseTotalsRecords(body []byte, teams map[int]string) map[string][]string {
+	result := make(map[string][]string)
+	for _, stmt := range strings.Split(string(body), ";") {
+		//arrTeam 是隊伍資料，其他的陣列才是大小分
+		if strings.Contains(stmt, "arrTeam") {
+			continue
+		}
+		for _, row := range jsArrays(stmt) {
+			if len(row) <= totalsFieldRecent {
+				continue
+			}
+			id, err := strconv.Atoi(row[totalsFieldTeam])
+			if err != nil || teams[id] == "" {
+				continue
+			}
+
+			var recent []string
+			for _, v := range row[totalsFieldRecent:] {
+				code := totalCode(strings.TrimSpace(v))
+				if code == "" {
+					break
+				}
+				recent = append(recent, code)
+			}
+			if len(recent) > 0 {
+				result[teams[id]] = recent
+			}
+		}
+	}
+	return result
+}
+
+//某個球季每隊的近期大小分結果
+func loadTotalsRecords(season string) (map[string][]string, error) {
+	teams, err := titanTeams(season)
+	if err != nil {
+		return nil, err
+	}
+	body, err := loadTotalsData(season)
+	if err != nil {
+		return nil, err
+	}
+	return parseTotalsRecords(body, teams), nil
+}
+
+//某隊的近期大小分
+//
+//matchResult 有盤口可以算平均，但大小分欄位是依位置取的，所以用 bs1.js 的結果驗證：
+//兩邊重疊的場次結果不同就不相信 matchResult，只用 bs1.js 的結果
+func teamTotalsSummary(matches []TitanMatch, records map[string][]string, team string, n int) TotalsSummary {
+	result := teamTotals(matches, team, n)
+
+	var recent []string
+	for name, v := range records {
+		if sameTeam(name, team) {
+			recent = v
+		}
+	}
+	if len(recent) == 0 {
+		return result
+	}
+
+	k := len(recent)
+	if len(result.Results) < k {
+		k = len(result.Results)
+	}
+	for i := 0; i < k; i++ {
+		if result.Results[i] != recent[i] {
+			fmt.Println(team, "matchResult 的大小分和 bs1.js 不一致，改用 bs1.js")
+			k = -1
+			break
+		}
+	}
+	if k > 0 {
+		return result
+	}
+
+	if len(recent) > n {
+		recent = recent[:n]
+	}
+	return TotalsSummary{Results: recent, Source: totalsFromRecords}
+}
+
+//用 titan007 的大小分盤口和最終比分算出某隊最近 n 場的結果
+func teamTotals(matches []TitanMatch, team string, n int) (result TotalsSummary) {
+	recent := recentTitanMatches(matches, team, n, func(m TitanMatch) bool {
+		return m.HasTotal
+	})
+	if len(recent) > 0 {
+		result.Source = totalsFromMatches
+	}
+
+	var sum float64
+	for _, m := range recent {
+		points := float64(m.HomeScore + m.AwayScore)
+		switch {
+		case points > m.Total:
+			result.Results = append(result.Results, TotalOver)
+		case points < m.Total:
+			result.Results = append(result.Results, TotalUnder)
+		default:
+			result.Results = append(result.Results, TotalPush)
+		}
+		sum = sum + m.Total
+	}
+
+	if len(recent) > 0 {
+		result.AvgLine = sum / float64(len(recent))
+	}
+	return result
+}
+
+//eg: "大,小,大,走,小 平均盤口 225.5"
+func (t TotalsSummary) Text() string {
+	if len(t.Results) == 0 {
+		return "無資料"
+	}
+
+	var labels []string
+	for _, r := range t.Results {
+		labels = append(labels, totalLabel(r))
+	}
+	if t.AvgLine == 0 {
+		return strings.Join(labels, ",")
+	}
+	return strings.Join(labels, ",") + fmt.Sprintf(" 平均盤口 %.1f", t.AvgLine)
+}
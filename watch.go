@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//報告上的時間都是台灣時間
+var taipei = time.FixedZone("UTC+8", 8*60*60)
+
+//scanNBA watch [-interval 15m] [-preview 06:30] [-snapshot file] [-slate] [-n 5] [-skip-if-necessary] [-season 2022-23]
+//
+//常駐執行，定時比對傷兵名單，越接近開賽越常檢查，每天早上送一次當天的預覽
+//收到 SIGINT/SIGTERM 會在目前這一輪結束後停止
+func watchCmd(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 15*time.Minute, "平常檢查傷兵的間隔")
+	//美東晚上的比賽大多在台灣時間 07:00~10:30 開打，預覽要在第一場之前送
+	preview := fs.String("preview", "06:30", "每天送預覽的時間(台灣時間)，空白表示不送")
+	snapshotPath := fs.String("snapshot", "injury-snapshot.json", "傷兵快照檔案")
+	slateOnly := fs.Bool("slate", true, "只通知今天有比賽的隊伍")
+	fs.IntVar(&atsLookback, "n", atsLookback, "讓分和大小分看最近幾場")
+	fs.BoolVar(&skipIfNecessary, "skip-if-necessary", skipIfNecessary, "略過季後賽「如果需要」才打的比賽")
+	seasonFlag(fs)
+	fs.Parse(args)
+
+	if err := checkCurrentSeason("watch"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var nextPreview time.Time
+	if *preview != "" {
+		if _, err := time.Parse("15:04", *preview); err != nil {
+			fmt.Println("時間格式錯誤: ", err)
+			return
+		}
+		nextPreview = nextClock(time.Now(), *preview)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("開始 watch，間隔", *interval)
+	for {
+		schedule, err := getSchedule(gameDate(time.Now()))
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		//只通知今天的隊伍時要有賽程，讀不到就跳過這一輪，快照留到下一輪再更新，變化才不會被過濾掉
+		if err != nil && *slateOnly {
+			fmt.Println("讀不到今天的賽程，這一輪不比對傷兵")
+		} else {
+			watchInjuries(*snapshotPath, *slateOnly, schedule)
+		}
+
+		if !nextPreview.IsZero() && !time.Now().Before(nextPreview) {
+			PKTeam()
+			nextPreview = nextClock(time.Now(), *preview)
+		}
+
+		wait := pollInterval(*interval, time.Now(), schedule)
+		if !nextPreview.IsZero() && time.Until(nextPreview) < wait {
+			wait = time.Until(nextPreview)
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("停止 watch")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+//比對一次傷兵，有變化就通知
+func watchInjuries(snapshotPath string, slateOnly bool, schedule Schedule) {
+	changes, err := updateInjurySnapshot(snapshotPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if slateOnly {
+		changes = filterInjuryChanges(changes, slateTeams(schedule))
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	report := Report{Date: gameDate(time.Now()), InjuryChanges: changes}
+	fmt.Println(time.Now().In(taipei).Format("15:04"), injuryChangesText(changes))
+	notifyAll(notifiersFromEnv(), &report)
+}
+
+//下一次到 clock(15:04，台灣時間) 的時間點
+func nextClock(now time.Time, clock string) time.Time {
+	c, _ := time.Parse("15:04", clock)
+	now = now.In(taipei)
+	next := time.Date(now.Year(), now.Month(), now.Day(), c.Hour(), c.Minute(), 0, 0, taipei)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+//下一場還沒開打的比賽時間
+func nextTipOff(now time.Time, schedule Schedule) (next time.Time) {
+	for _, v := range schedule.Payload.Date.Games {
+		ms, err := strconv.ParseInt(v.Profile.UtcMillis, 10, 64)
+		if err != nil {
+			continue
+		}
+		t := time.Unix(0, ms*int64(time.Millisecond))
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next
+}
+
+//越接近開賽檢查越頻繁，最少一分鐘
+func pollInterval(interval time.Duration, now time.Time, schedule Schedule) time.Duration {
+	tip := nextTipOff(now, schedule)
+	if !tip.IsZero() {
+		switch until := tip.Sub(now); {
+		case until <= 30*time.Minute:
+			interval = interval / 4
+		case until <= 2*time.Hour:
+			interval = interval / 2
+		}
+	}
+
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
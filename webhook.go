@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//通用的 webhook，把 JSON 報告 POST 到任意網址
+//
+//每個 request 會帶上
+//
+//	X-ScanNBA-Timestamp  unix 秒數
+//	X-ScanNBA-Signature  "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+//	Idempotency-Key      同一個比賽日固定，接收端可以用來去重
+//
+//失敗會重試，最後還是失敗就寫進 dead-letter 檔案
+type webhook struct {
+	url        string
+	secret     string
+	deadLetter string
+	retries    int
+	backoff    time.Duration
+}
+
+//寫進 dead-letter 檔案的一行
+type deadLetter struct {
+	URL            string          `json:"url"`
+	IdempotencyKey string          `json:"idempotencyKey"`
+	FailedAt       string          `json:"failedAt"`
+	Error          string          `json:"error"`
+	Body           json.RawMessage `json:"body"`
+}
+
+//從環境變數讀取 webhook 設定
+//
+//	WEBHOOK_URLS        要 POST 的網址，多個用逗號分開
+//	WEBHOOK_SECRET      簽章用的 secret
+//	WEBHOOK_DEADLETTER  失敗時寫入的檔案，預設 webhook-deadletter.jsonl
+func webhooksFromEnv() (result []Notifier) {
+	deadLetter := os.Getenv("WEBHOOK_DEADLETTER")
+	if deadLetter == "" {
+		deadLetter = "webhook-deadletter.jsonl"
+	}
+
+	for _, u := range splitEnv("WEBHOOK_URLS") {
+		result = append(result, &webhook{
+			url:        u,
+			secret:     os.Getenv("WEBHOOK_SECRET"),
+			deadLetter: deadLetter,
+			retries:    3,
+			backoff:    time.Second,
+		})
+	}
+	return result
+}
+
+func (w *webhook) Name() string { return "webhook:" + w.url }
+
+//同一天的報告用同一個 key，傷兵異動則依內容產生，不然同一天的第二次異動會被去重
+func idempotencyKey(r *Report) string {
+	if len(r.InjuryChanges) > 0 {
+		body, _ := json.Marshal(r.InjuryChanges)
+		sum := sha256.Sum256(body)
+		return "scannba-injury-" + r.Date + "-" + hex.EncodeToString(sum[:8])
+	}
+	return "scannba-report-" + r.Date
+}
+
+//計算簽章
+func signPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhook) Notify(r *Report) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	key := idempotencyKey(r)
+	wait := w.backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = w.send(key, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.retries {
+			break
+		}
+		time.Sleep(wait)
+		wait = wait * 2
+	}
+
+	if dlErr := w.writeDeadLetter(key, body, err); dlErr != nil {
+		return fmt.Errorf("%v (dead-letter: %v)", err, dlErr)
+	}
+	return err
+}
+
+//送出一次，回傳是否值得重試
+func (w *webhook) send(key string, body []byte) (bool, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scanNBA")
+	req.Header.Set("Idempotency-Key", key)
+	req.Header.Set("X-ScanNBA-Timestamp", timestamp)
+	if w.secret != "" {
+		req.Header.Set("X-ScanNBA-Signature", signPayload(w.secret, timestamp, body))
+	}
+
+	res, err := notifyClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return false, nil
+	}
+
+	resBody, _ := ioutil.ReadAll(res.Body)
+	err = fmt.Errorf("status code error: %d %s", res.StatusCode, strings.TrimSpace(string(resBody)))
+	//4xx 除了 408、429 之外重試也沒用
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+func (w *webhook) writeDeadLetter(key string, body []byte, sendErr error) error {
+	line, err := json.Marshal(deadLetter{
+		URL:            w.url,
+		IdempotencyKey: key,
+		FailedAt:       time.Now().Format(time.RFC3339),
+		Error:          sendErr.Error(),
+		Body:           body,
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.deadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}